@@ -0,0 +1,22 @@
+// Package bencode implements encoding and decoding of bencoded data, the
+// serialization format used by BitTorrent metainfo files, tracker responses
+// and peer extension messages.
+//
+// Decoded values use the following Go types:
+//
+//	byte string -> []byte
+//	integer     -> int64
+//	list        -> []interface{}
+//	dictionary  -> map[string]interface{}
+//
+// Byte strings are arbitrary binary data, such as the piece hashes of a
+// torrent, so they are kept as []byte. Dictionary keys are converted to
+// string. Bencode integers have no size limit: integers that do not fit in
+// an int64 are an error unless Decoder.UseBigInt is called, in which case
+// they decode to *big.Int. Unmarshal and Marshal also accept big.Int and
+// *big.Int values.
+package bencode
+
+// RawMessage is a raw encoded bencode value. Encode writes it unchanged,
+// which allows parts of a value to be copied byte for byte.
+type RawMessage []byte
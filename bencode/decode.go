@@ -0,0 +1,500 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits a Decoder starts with. They bound the memory a hostile input can
+// make the decoder use.
+const (
+	// DefaultMaxDepth is the default maximum nesting depth of lists and
+	// dictionaries.
+	DefaultMaxDepth = 256
+	// DefaultMaxStringLength is the default maximum length of a byte
+	// string. It allows the piece hashes of torrents of several terabytes.
+	DefaultMaxStringLength = 1 << 26
+)
+
+// Bounds of the tokens of byte string lengths and integers.
+const (
+	maxLengthDigits = 19
+	maxIntLength    = 1024
+)
+
+// stringChunk is the number of bytes of a byte string read at a time, so
+// that memory is only allocated for data that is actually there.
+const stringChunk = 1 << 16
+
+// A Decoder reads bencoded values from an input stream. Successive calls
+// read successive values; Decode returns io.EOF once the input ends
+// between values, and io.ErrUnexpectedEOF if it ends inside one.
+type Decoder struct {
+	r *bufio.Reader
+	// off is the number of bytes consumed from the input.
+	off int64
+	// raw collects the consumed bytes while DecodeRaw is reading a value.
+	raw *bytes.Buffer
+
+	depth           int
+	maxDepth        int
+	maxStringLength int
+	strict          bool
+	useBigInt       bool
+}
+
+// A SyntaxError describes invalid bencode and the offset in the input
+// where it was found.
+type SyntaxError struct {
+	Msg    string
+	Offset int64
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
+
+func syntaxError(off int64, format string, args ...interface{}) error {
+	return &SyntaxError{Msg: fmt.Sprintf(format, args...), Offset: off}
+}
+
+// NewDecoder returns a new decoder that reads from r, with the default
+// limits.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:               bufio.NewReader(r),
+		maxDepth:        DefaultMaxDepth,
+		maxStringLength: DefaultMaxStringLength,
+	}
+}
+
+// SetMaxDepth sets the maximum nesting depth of lists and dictionaries. A
+// value of n <= 0 removes the limit.
+func (d *Decoder) SetMaxDepth(n int) {
+	d.maxDepth = n
+}
+
+// SetMaxStringLength sets the maximum length of a byte string. A value of
+// n <= 0 removes the limit.
+func (d *Decoder) SetMaxStringLength(n int) {
+	d.maxStringLength = n
+}
+
+// SetStrict makes the decoder reject input that is not in the canonical
+// encoding: integers and lengths with leading zeros or a sign other than
+// the minus of a negative integer, "-0", and dictionaries whose keys are
+// not unique and sorted. Strict decoding is what guarantees that encoding
+// a decoded value gives back the same bytes.
+func (d *Decoder) SetStrict(strict bool) {
+	d.strict = strict
+}
+
+// UseBigInt makes the decoder return integers that do not fit in an int64
+// as *big.Int instead of failing.
+func (d *Decoder) UseBigInt() {
+	d.useBigInt = true
+}
+
+// Decode reads the next bencoded value from the input.
+func (d *Decoder) Decode() (interface{}, error) {
+	start := d.off
+	val, err := d.decode()
+	return val, d.eof(start, err)
+}
+
+// DecodeInto reads the next bencoded value from the input and stores it in
+// the value pointed to by v, as Unmarshal does.
+func (d *Decoder) DecodeInto(v interface{}) error {
+	start := d.off
+	return d.eof(start, d.unmarshal(v))
+}
+
+// DecodeRaw reads the next bencoded value from the input and returns its
+// encoding exactly as it appears in the input.
+func (d *Decoder) DecodeRaw() (RawMessage, error) {
+	start := d.off
+	raw, err := d.decodeRaw()
+	return raw, d.eof(start, err)
+}
+
+func (d *Decoder) decodeRaw() (RawMessage, error) {
+	outer := d.raw
+	buf := &bytes.Buffer{}
+	d.raw = buf
+	_, err := d.decode()
+	d.raw = outer
+	if outer != nil {
+		outer.Write(buf.Bytes())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return RawMessage(buf.Bytes()), nil
+}
+
+// eof turns io.EOF into io.ErrUnexpectedEOF if the input ended after the
+// offset start, inside a value.
+func (d *Decoder) eof(start int64, err error) error {
+	if err == io.EOF && d.off > start {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// InputOffset returns the number of bytes of the input consumed so far,
+// which is the offset of the next value.
+func (d *Decoder) InputOffset() int64 {
+	return d.off
+}
+
+// Decode decodes the first bencoded value in b.
+func Decode(b []byte) (interface{}, error) {
+	return NewDecoder(bytes.NewReader(b)).Decode()
+}
+
+// Check reports whether b holds exactly one value in the canonical
+// encoding. It returns a *SyntaxError locating the first problem found.
+func Check(b []byte) error {
+	d := NewDecoder(bytes.NewReader(b))
+	d.SetStrict(true)
+	if _, err := d.Decode(); err != nil {
+		return d.positioned(err)
+	}
+	return d.end()
+}
+
+// end returns an error unless the input is exhausted.
+func (d *Decoder) end() error {
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return syntaxError(d.off, "trailing data after the value")
+}
+
+// positioned gives io.ErrUnexpectedEOF the offset where the input ended.
+func (d *Decoder) positioned(err error) error {
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return syntaxError(d.off, "unexpected end of input")
+	}
+	return err
+}
+
+// DecodeFile decodes the first bencoded value in the named file.
+func DecodeFile(name string) (interface{}, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewDecoder(f).Decode()
+}
+
+// RawDict decodes the dictionary at the start of b and returns the
+// encoding of each of its values exactly as it appears in b. Hashing
+// RawDict(metainfo)["info"] gives the info hash of a torrent whatever the
+// encoder that produced it.
+func RawDict(b []byte) (map[string]RawMessage, error) {
+	d := NewDecoder(bytes.NewReader(b))
+	dict, err := d.rawDict()
+	return dict, d.eof(0, err)
+}
+
+func (d *Decoder) rawDict() (map[string]RawMessage, error) {
+	if err := d.expect('d'); err != nil {
+		return nil, err
+	}
+	dict := make(map[string]RawMessage)
+	var key []byte
+	for {
+		if c, err := d.r.Peek(1); err != nil {
+			return dict, err
+		} else if c[0] == 'e' {
+			return dict, nil
+		}
+
+		var err error
+		key, err = d.dictKey(key)
+		if err != nil {
+			return dict, err
+		}
+		val, err := d.decodeRaw()
+		if err != nil {
+			return dict, err
+		}
+		dict[string(key)] = val
+	}
+}
+
+// read consumes the bytes in b, keeping track of the offset and recording
+// them for DecodeRaw.
+func (d *Decoder) read(b []byte) {
+	d.off += int64(len(b))
+	if d.raw != nil {
+		d.raw.Write(b)
+	}
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	d.read([]byte{c})
+	return c, nil
+}
+
+// readToken consumes the bytes up to and including delim, which must come
+// within max bytes.
+func (d *Decoder) readToken(delim byte, max int) (string, error) {
+	var token []byte
+	for len(token) <= max {
+		c, err := d.readByte()
+		if err != nil {
+			return "", err
+		}
+		token = append(token, c)
+		if c == delim {
+			return string(token), nil
+		}
+	}
+	return "", syntaxError(d.off-int64(len(token)), "%q not found within %d bytes", delim, max)
+}
+
+// enter is called when a list or dictionary starts, and leave when it
+// ends, to keep track of the nesting depth.
+func (d *Decoder) enter() error {
+	d.depth++
+	if d.maxDepth > 0 && d.depth > d.maxDepth {
+		return syntaxError(d.off-1, "nesting depth exceeds the limit of %d", d.maxDepth)
+	}
+	return nil
+}
+
+func (d *Decoder) leave() {
+	d.depth--
+}
+
+// expect consumes the next byte, which must be c.
+func (d *Decoder) expect(c byte) error {
+	b, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	if b != c {
+		return syntaxError(d.off-1, "expected %q, got %q", c, b)
+	}
+	return nil
+}
+
+func (d *Decoder) decode() (interface{}, error) {
+	c, err := d.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	first := c[0]
+	switch {
+	case unicode.IsDigit(rune(first)):
+		return d.decodeString()
+	case first == 'i':
+		return d.decodeInt()
+	case first == 'l':
+		return d.decodeList()
+	case first == 'd':
+		return d.decodeDict()
+	default:
+		return nil, syntaxError(d.off, "invalid value starting with %q", first)
+	}
+}
+
+func (d *Decoder) decodeString() ([]byte, error) {
+	off := d.off
+	num, err := d.readToken(':', maxLengthDigits+1)
+	if err != nil {
+		return nil, err
+	}
+
+	digits := num[:len(num)-1]
+	length, err := strconv.Atoi(digits)
+	if err != nil {
+		return nil, syntaxError(off, "invalid string length %q", digits)
+	}
+	if length < 0 {
+		return nil, syntaxError(off, "negative string length %d", length)
+	}
+	if d.strict && (digits[0] < '0' || digits[0] > '9' || len(digits) > 1 && digits[0] == '0') {
+		return nil, syntaxError(off, "non-canonical string length %q", digits)
+	}
+	if d.maxStringLength > 0 && length > d.maxStringLength {
+		return nil, syntaxError(off, "string of %d bytes exceeds the limit of %d", length, d.maxStringLength)
+	}
+	// grow the string as data arrives rather than trusting the length
+	str := make([]byte, 0, min(length, stringChunk))
+	for len(str) < length {
+		n := min(length-len(str), stringChunk)
+		str = slices.Grow(str, n)
+		got, err := io.ReadFull(d.r, str[len(str):len(str)+n])
+		d.read(str[len(str) : len(str)+got])
+		str = str[:len(str)+got]
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return str, nil
+}
+
+func (d *Decoder) decodeInt() (interface{}, error) {
+	off := d.off
+	digits, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if !d.useBigInt {
+			return nil, syntaxError(off, "integer %s does not fit in 64 bits", digits)
+		}
+		return parseBigInt(digits, off)
+	}
+	if err != nil {
+		return nil, syntaxError(off, "invalid integer %q", digits)
+	}
+	return n, nil
+}
+
+// parseBigInt parses the digits of an integer at offset off of any size.
+func parseBigInt(digits string, off int64) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, syntaxError(off, "invalid integer %q", digits)
+	}
+	return n, nil
+}
+
+// readInt consumes an integer and returns its digits. In strict mode, they
+// must be in canonical form.
+func (d *Decoder) readInt() (string, error) {
+	off := d.off
+	token, err := d.readToken('e', maxIntLength)
+	if err != nil {
+		return "", err
+	}
+	digits := token[1 : len(token)-1]
+	if d.strict && !canonicalInt(digits) {
+		return "", syntaxError(off, "non-canonical integer %q", digits)
+	}
+	return digits, nil
+}
+
+// canonicalInt reports whether s is an integer without leading zeros or a
+// plus sign, and not "-0".
+func canonicalInt(s string) bool {
+	if s == "0" {
+		return true
+	}
+	s = strings.TrimPrefix(s, "-")
+	if s == "" || s[0] == '0' {
+		return false
+	}
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// dictKey reads the key of a dictionary entry. prev is the previous key of
+// the dictionary, nil for the first one, which in strict mode must sort
+// before the key.
+func (d *Decoder) dictKey(prev []byte) ([]byte, error) {
+	off := d.off
+	if c, err := d.r.Peek(1); err != nil {
+		return nil, err
+	} else if c[0] < '0' || c[0] > '9' {
+		return nil, syntaxError(off, "dictionary key is not a byte string")
+	}
+	key, err := d.decodeString()
+	if err != nil {
+		return nil, err
+	}
+	if d.strict && prev != nil {
+		switch bytes.Compare(prev, key) {
+		case 0:
+			return nil, syntaxError(off, "duplicate dictionary key %q", key)
+		case 1:
+			return nil, syntaxError(off, "dictionary key %q is not sorted after %q", key, prev)
+		}
+	}
+	return key, nil
+}
+
+func (d *Decoder) decodeList() ([]interface{}, error) {
+	d.readByte()
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	list := make([]interface{}, 0)
+	for {
+		if c, err := d.r.Peek(1); err != nil {
+			return list, err
+		} else if c[0] == 'e' {
+			d.readByte()
+			break
+		}
+
+		if val, err := d.decode(); err != nil {
+			return list, err
+		} else {
+			list = append(list, val)
+		}
+	}
+	return list, nil
+}
+
+func (d *Decoder) decodeDict() (map[string]interface{}, error) {
+	d.readByte()
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	dict := make(map[string]interface{})
+	var key []byte
+	for {
+		if c, err := d.r.Peek(1); err != nil {
+			return dict, err
+		} else if c[0] == 'e' {
+			d.readByte()
+			break
+		}
+
+		var val interface{}
+		var err error
+		if key, err = d.dictKey(key); err != nil {
+			return dict, err
+		}
+		if val, err = d.decode(); err != nil {
+			return dict, err
+		}
+
+		dict[string(key)] = val
+	}
+	return dict, nil
+}
@@ -0,0 +1,168 @@
+package bencode
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"i42e", int64(42)},
+		{"i-42e", int64(-42)},
+		{"i0e", int64(0)},
+		{"0:", []byte{}},
+		{"5:hello", []byte("hello")},
+		{"le", []interface{}{}},
+		{"li1e3:abce", []interface{}{int64(1), []byte("abc")}},
+		{"de", map[string]interface{}{}},
+		{"d3:fooli1ei-2e3:bare4:spam5:helloe", map[string]interface{}{
+			"foo":  []interface{}{int64(1), int64(-2), []byte("bar")},
+			"spam": []byte("hello"),
+		}},
+		// Decode is lenient about what Check rejects.
+		{"i03e", int64(3)},
+		{"d1:bi2e1:ai1ee", map[string]interface{}{"a": int64(1), "b": int64(2)}},
+		{"i1etrailing", int64(1)},
+	}
+	for _, tt := range tests {
+		got, err := Decode([]byte(tt.in))
+		if err != nil {
+			t.Errorf("Decode(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Decode(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "EOF"},
+		{"i12", "unexpected EOF"},
+		{"5:abc", "unexpected EOF"},
+		{"l", "unexpected EOF"},
+		{"x", "invalid value starting with 'x' at offset 0"},
+		{"ie", `invalid integer "" at offset 0`},
+		{"i1-2e", `invalid integer "1-2" at offset 0`},
+		{"di1ei1ee", "dictionary key is not a byte string at offset 1"},
+	}
+	for _, tt := range tests {
+		if _, err := Decode([]byte(tt.in)); err == nil || err.Error() != tt.want {
+			t.Errorf("Decode(%q) error = %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		in     string
+		msg    string
+		offset int64
+	}{
+		{"i03e", `non-canonical integer "03"`, 0},
+		{"i-0e", `non-canonical integer "-0"`, 0},
+		{"03:abc", `non-canonical string length "03"`, 0},
+		{"i1e2", "trailing data after the value", 3},
+		{"d1:b0:1:a0:e", `dictionary key "a" is not sorted after "b"`, 6},
+		{"d1:a0:1:a0:e", `duplicate dictionary key "a"`, 6},
+		{"li1ed1:bi1e1:ai2eee", `dictionary key "a" is not sorted after "b"`, 11},
+		{"x", "invalid value starting with 'x'", 0},
+		{"i12", "unexpected end of input", 3},
+		{"1:", "unexpected end of input", 2},
+	}
+	for _, tt := range tests {
+		err := Check([]byte(tt.in))
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("Check(%q) = %v, want a *SyntaxError", tt.in, err)
+			continue
+		}
+		if serr.Msg != tt.msg || serr.Offset != tt.offset {
+			t.Errorf("Check(%q) = %q at %d, want %q at %d", tt.in, serr.Msg, serr.Offset, tt.msg, tt.offset)
+		}
+	}
+
+	for _, in := range []string{"i0e", "i-1e", "0:", "le", "de", "d1:ai1e1:bi2ee", "d3:fooli1ei-2e3:bare4:spam5:helloe"} {
+		if err := Check([]byte(in)); err != nil {
+			t.Errorf("Check(%q) = %v, want nil", in, err)
+		}
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	d := NewDecoder(strings.NewReader("llleee"))
+	d.SetMaxDepth(2)
+	if _, err := d.Decode(); err == nil || err.Error() != "nesting depth exceeds the limit of 2 at offset 2" {
+		t.Errorf("Decode() with depth 3 error = %v", err)
+	}
+
+	d = NewDecoder(strings.NewReader("5:abcde"))
+	d.SetMaxStringLength(4)
+	if _, err := d.Decode(); err == nil || err.Error() != "string of 5 bytes exceeds the limit of 4 at offset 0" {
+		t.Errorf("Decode() of a 5 byte string error = %v", err)
+	}
+}
+
+func TestDecoderStream(t *testing.T) {
+	d := NewDecoder(strings.NewReader("i1e3:abcle"))
+	for _, want := range []interface{}{int64(1), []byte("abc"), []interface{}{}} {
+		got, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode() = %#v, want %#v", got, want)
+		}
+	}
+	if d.InputOffset() != 10 {
+		t.Errorf("InputOffset() = %d, want 10", d.InputOffset())
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("Decode() at the end error = %v, want io.EOF", err)
+	}
+}
+
+func TestDecodeBigInt(t *testing.T) {
+	const huge = "123456789012345678901234567890"
+	if _, err := Decode([]byte("i" + huge + "e")); err == nil || err.Error() != "integer "+huge+" does not fit in 64 bits at offset 0" {
+		t.Errorf("Decode() of a huge integer error = %v", err)
+	}
+
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"i9223372036854775807e", int64(9223372036854775807)},
+		{"i-9223372036854775808e", int64(-9223372036854775808)},
+		{"i9223372036854775808e", bigInt("9223372036854775808")},
+		{"i-" + huge + "e", bigInt("-" + huge)},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(strings.NewReader(tt.in))
+		d.UseBigInt()
+		got, err := d.Decode()
+		if err != nil {
+			t.Errorf("Decode(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Decode(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
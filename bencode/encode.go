@@ -0,0 +1,185 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type encoder struct {
+	*bytes.Buffer
+}
+
+var (
+	rawMessageType = reflect.TypeOf(RawMessage(nil))
+	bigIntType     = reflect.TypeOf(big.Int{})
+)
+
+// Encode returns the bencoding of val. It accepts the same types Decode
+// produces, as well as string for byte strings and RawMessage for values
+// that are already encoded; dictionary keys are written in sorted order.
+// Any other type is encoded as by Marshal.
+func Encode(val interface{}) ([]byte, error) {
+	return Marshal(val)
+}
+
+// Marshal returns the bencoding of v.
+//
+// Strings and byte slices are encoded as byte strings, integers of any
+// size, including big.Int, as integers, booleans as the integers 0 and 1,
+// slices and arrays as lists, and maps with string keys as dictionaries. A
+// RawMessage is written as it is. Pointers and interfaces are encoded as
+// the value they point to; nil ones are left out of lists and
+// dictionaries, since bencode has no null value.
+//
+// A struct is encoded as a dictionary of its exported fields. The
+// dictionary key of a field is the name in its "bencode" tag, or the field
+// name if it has none; the option "omitempty" leaves the field out if it
+// holds its zero value or is an empty slice, map or string, and the tag
+// "-" always leaves it out.
+func Marshal(v interface{}) ([]byte, error) {
+	e := encoder{&bytes.Buffer{}}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// An Encoder writes bencoded values to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the bencoding of v, as returned by Marshal, to the stream.
+// Nothing is written if v cannot be encoded.
+func (enc *Encoder) Encode(v interface{}) error {
+	b, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(b)
+	return err
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		return fmt.Errorf("cannot encode a nil value")
+	}
+	if v.Type() == rawMessageType {
+		if v.Len() == 0 {
+			return fmt.Errorf("empty raw value in encoder")
+		}
+		e.Write(v.Bytes())
+		return nil
+	}
+	if v.Type() == bigIntType {
+		n := new(big.Int)
+		reflect.ValueOf(n).Elem().Set(v)
+		fmt.Fprintf(e, "i%se", n)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		e.WriteString(strconv.Itoa(v.Len()))
+		e.WriteByte(':')
+		e.WriteString(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprintf(e, "i%de", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fmt.Fprintf(e, "i%de", v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			e.WriteString("i1e")
+		} else {
+			e.WriteString("i0e")
+		}
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return fmt.Errorf("cannot encode a nil %s", v.Type())
+		}
+		return e.encode(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.WriteString(strconv.Itoa(v.Len()))
+			e.WriteByte(':')
+			if v.Kind() == reflect.Slice {
+				e.Write(v.Bytes())
+			} else {
+				for i := range v.Len() {
+					e.WriteByte(byte(v.Index(i).Uint()))
+				}
+			}
+			return nil
+		}
+		e.WriteByte('l')
+		for i := range v.Len() {
+			if isNil(v.Index(i)) {
+				continue
+			}
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.WriteByte('e')
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type in encoder: %s", v.Type().Key())
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+
+		e.WriteByte('d')
+		for _, key := range keys {
+			val := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+			if isNil(val) {
+				continue
+			}
+			e.encode(reflect.ValueOf(key))
+			if err := e.encode(val); err != nil {
+				return err
+			}
+		}
+		e.WriteByte('e')
+	case reflect.Struct:
+		e.WriteByte('d')
+		for _, f := range structFields(v.Type()) {
+			val := v.Field(f.index)
+			if isNil(val) || f.omitEmpty && isEmpty(val) {
+				continue
+			}
+			e.encode(reflect.ValueOf(f.name))
+			if err := e.encode(val); err != nil {
+				return fmt.Errorf("field %s: %w", v.Type().Field(f.index).Name, err)
+			}
+		}
+		e.WriteByte('e')
+	default:
+		return fmt.Errorf("unsupported type in encoder: %s", v.Type())
+	}
+	return nil
+}
+
+// isNil reports whether v is a nil pointer, interface or RawMessage, which
+// is left out of lists and dictionaries.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice:
+		return v.Type() == rawMessageType && v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package bencode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Keys of the JSON objects that stand for byte strings and dictionaries
+// that a plain JSON value cannot represent.
+const (
+	jsonBase64Key = "$base64"
+	jsonHexKey    = "$hex"
+	jsonDictKey   = "$dict"
+)
+
+// ToJSON returns val, a value as returned by Decode, in a form that
+// encoding/json marshals without loss and that FromJSON turns back into
+// the same bencode:
+//
+//   - byte strings that are valid UTF-8 become JSON strings, others an
+//     object {"$base64": "..."} holding their base64 encoding;
+//   - integers become JSON numbers of any size;
+//   - lists become arrays and dictionaries objects, except for
+//     dictionaries with a key that is not valid UTF-8 or that would be
+//     mistaken for one of these tagged objects, which become
+//     {"$dict": [[key, value], ...]}.
+func ToJSON(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []byte:
+		return jsonString(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = ToJSON(e)
+		}
+		return list
+	case map[string]interface{}:
+		if !plainJSONDict(v) {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			pairs := make([]interface{}, len(keys))
+			for i, k := range keys {
+				pairs[i] = []interface{}{jsonString([]byte(k)), ToJSON(v[k])}
+			}
+			return map[string]interface{}{jsonDictKey: pairs}
+		}
+		dict := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			dict[k] = ToJSON(e)
+		}
+		return dict
+	}
+	return val
+}
+
+func jsonString(b []byte) interface{} {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return map[string]interface{}{jsonBase64Key: base64.StdEncoding.EncodeToString(b)}
+}
+
+// plainJSONDict reports whether dict can be represented by a JSON object
+// with the same keys.
+func plainJSONDict(dict map[string]interface{}) bool {
+	if len(dict) == 1 {
+		for k := range dict {
+			if k == jsonBase64Key || k == jsonHexKey || k == jsonDictKey {
+				return false
+			}
+		}
+	}
+	for k := range dict {
+		if !utf8.ValidString(k) {
+			return false
+		}
+	}
+	return true
+}
+
+// FromJSON parses JSON in the form produced by ToJSON and returns it as a
+// value that Encode accepts. Byte strings may also be given as
+// {"$hex": "..."}. JSON numbers must be integers; booleans and null are
+// rejected since bencode has no equivalent.
+func FromJSON(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var val interface{}
+	if err := d.Decode(&val); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("trailing data after the JSON value")
+	}
+	return fromJSON(val)
+}
+
+func fromJSON(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case string:
+		return []byte(v), nil
+	case json.Number:
+		if n, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return n, nil
+		}
+		n, ok := new(big.Int).SetString(v.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("number %s is not an integer", v)
+		}
+		return n, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if list[i], err = fromJSON(e); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for k, e := range v {
+				switch k {
+				case jsonBase64Key, jsonHexKey:
+					return fromJSONBytes(k, e)
+				case jsonDictKey:
+					return fromJSONPairs(e)
+				}
+			}
+		}
+		dict := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if dict[k], err = fromJSON(e); err != nil {
+				return nil, fmt.Errorf("%q: %w", k, err)
+			}
+		}
+		return dict, nil
+	case bool:
+		return nil, fmt.Errorf("bencode has no booleans, use 0 or 1")
+	case nil:
+		return nil, fmt.Errorf("bencode has no null")
+	}
+	return nil, fmt.Errorf("unsupported JSON value %v", val)
+}
+
+// fromJSONBytes decodes the value of a {"$base64": ...} or {"$hex": ...}
+// object.
+func fromJSONBytes(key string, val interface{}) ([]byte, error) {
+	s, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s value is not a string", key)
+	}
+	var b []byte
+	var err error
+	if key == jsonHexKey {
+		b, err = hex.DecodeString(s)
+	} else {
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value: %w", key, err)
+	}
+	return b, nil
+}
+
+// fromJSONPairs decodes the value of a {"$dict": [[key, value], ...]}
+// object.
+func fromJSONPairs(val interface{}) (map[string]interface{}, error) {
+	pairs, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s value is not a list", jsonDictKey)
+	}
+	dict := make(map[string]interface{}, len(pairs))
+	for _, p := range pairs {
+		pair, ok := p.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("%s entry is not a [key, value] pair", jsonDictKey)
+		}
+		key, err := fromJSON(pair[0])
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("%s key is not a string", jsonDictKey)
+		}
+		if dict[string(k)], err = fromJSON(pair[1]); err != nil {
+			return nil, fmt.Errorf("%q: %w", k, err)
+		}
+	}
+	return dict, nil
+}
@@ -0,0 +1,92 @@
+package bencode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		in   string
+		json string
+	}{
+		{"i42e", `42`},
+		{"i123456789012345678901234567890e", `123456789012345678901234567890`},
+		{"5:hello", `"hello"`},
+		{"3:\xff\x00\x01", `{"$base64":"/wAB"}`},
+		{"li1e1:ae", `[1,"a"]`},
+		{"d3:fooli1ei-2e3:bare4:spam5:helloe", `{"foo":[1,-2,"bar"],"spam":"hello"}`},
+		{"d1:\xffi1ee", `{"$dict":[[{"$base64":"/w=="},1]]}`},
+		{"d7:$base643:abce", `{"$dict":[["$base64","abc"]]}`},
+		{"d7:$base643:abc1:xi1ee", `{"$base64":"abc","x":1}`},
+		{"de", `{}`},
+		{"le", `[]`},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(strings.NewReader(tt.in))
+		d.UseBigInt()
+		val, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decode(%q) error: %v", tt.in, err)
+		}
+		b, err := json.Marshal(ToJSON(val))
+		if err != nil {
+			t.Fatalf("json.Marshal(ToJSON(%q)) error: %v", tt.in, err)
+		}
+		if string(b) != tt.json {
+			t.Errorf("ToJSON(%q) = %s, want %s", tt.in, b, tt.json)
+		}
+
+		back, err := FromJSON(b)
+		if err != nil {
+			t.Fatalf("FromJSON(%s) error: %v", b, err)
+		}
+		enc, err := Encode(back)
+		if err != nil {
+			t.Fatalf("Encode(FromJSON(%s)) error: %v", b, err)
+		}
+		if string(enc) != tt.in {
+			t.Errorf("round trip of %q = %q", tt.in, enc)
+		}
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`{"$hex":"ff00"}`, "2:\xff\x00"},
+		{`{"b":1,"a":"x"}`, "d1:a1:x1:bi1ee"},
+		{` [1, 2] `, "li1ei2ee"},
+	}
+	for _, tt := range tests {
+		val, err := FromJSON([]byte(tt.json))
+		if err != nil {
+			t.Errorf("FromJSON(%s) error: %v", tt.json, err)
+			continue
+		}
+		if b, err := Encode(val); err != nil || string(b) != tt.want {
+			t.Errorf("Encode(FromJSON(%s)) = %q, %v, want %q", tt.json, b, err, tt.want)
+		}
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	for _, in := range []string{
+		`true`,
+		`null`,
+		`1.5`,
+		`[1] [2]`,
+		`{"$hex":"zz"}`,
+		`{"$base64":1}`,
+		`{"$dict":[["a"]]}`,
+		`{"$dict":[[1,2]]}`,
+		`{"a":null}`,
+	} {
+		if val, err := FromJSON([]byte(in)); err == nil {
+			t.Errorf("FromJSON(%s) = %#v, want an error", in, val)
+		}
+	}
+}
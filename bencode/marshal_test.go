@@ -0,0 +1,185 @@
+package bencode
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+type testFile struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+}
+
+type testInfo struct {
+	Name     string     `bencode:"name"`
+	Length   *int64     `bencode:"length,omitempty"`
+	Files    []testFile `bencode:"files,omitempty"`
+	Private  bool       `bencode:"private,omitempty"`
+	Comment  string     `bencode:"comment,omitempty"`
+	Raw      RawMessage `bencode:"raw,omitempty"`
+	Skipped  string     `bencode:"-"`
+	NoTag    int
+	internal int
+}
+
+func TestMarshal(t *testing.T) {
+	length := int64(5)
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"integers", []interface{}{1, int8(-2), uint64(3), true, false}, "li1ei-2ei3ei1ei0ee"},
+		{"strings", []interface{}{"abc", []byte("de"), [2]byte{'f', 'g'}}, "l3:abc2:de2:fge"},
+		{"sorted map", map[string]int{"b": 2, "a": 1}, "d1:ai1e1:bi2ee"},
+		{"nil pointers skipped", []*int{nil}, "le"},
+		{"raw message", []RawMessage{RawMessage("d1:xi1ee")}, "ld1:xi1eee"},
+		{
+			"struct fields sorted by key bytes",
+			testInfo{Name: "a", Length: &length, Skipped: "x", NoTag: 7, internal: 8},
+			"d5:NoTagi7e6:lengthi5e4:name1:ae",
+		},
+		{
+			"omitempty",
+			testInfo{Name: "", Files: []testFile{{Length: 1, Path: []string{"d", "f"}}}, Private: true},
+			"d5:NoTagi0e5:filesld6:lengthi1e4:pathl1:d1:feee4:name0:7:privatei1ee",
+		},
+		{"raw field", testInfo{Raw: RawMessage("i1e")}, "d5:NoTagi0e4:name0:3:rawi1ee"},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.in)
+		if err != nil {
+			t.Errorf("%s: Marshal() error: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: Marshal() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	for _, in := range []interface{}{
+		nil,
+		(*int)(nil),
+		map[int]int{1: 1},
+		1.5,
+		[]RawMessage{{}},
+	} {
+		if got, err := Marshal(in); err == nil {
+			t.Errorf("Marshal(%#v) = %q, want an error", in, got)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	length := int64(5)
+	var info testInfo
+	in := "d7:comment2:hi5:extrali1ee6:lengthi5e4:name1:a5:NoTagi7e7:privatei1e3:rawd1:xi1ee7:Skipped1:xe"
+	if err := Unmarshal([]byte(in), &info); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	want := testInfo{Name: "a", Length: &length, Private: true, Comment: "hi", Raw: RawMessage("d1:xi1ee"), NoTag: 7}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", info, want)
+	}
+
+	var files []testFile
+	if err := Unmarshal([]byte("ld6:lengthi1e4:pathl1:aeee"), &files); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if want := []testFile{{Length: 1, Path: []string{"a"}}}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", files, want)
+	}
+
+	var m map[string]interface{}
+	if err := Unmarshal([]byte("d1:ai1e1:b1:xe"), &m); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if want := map[string]interface{}{"a": int64(1), "b": []byte("x")}; !reflect.DeepEqual(m, want) {
+		t.Errorf("Unmarshal() = %#v, want %#v", m, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		v    interface{}
+		want string
+	}{
+		{"i300e", new(int8), "integer 300 at offset 0 overflows int8"},
+		{"i-1e", new(uint), `invalid integer "-1" at offset 0`},
+		{"i1e", new(string), "cannot unmarshal integer at offset 0 into Go value of type string"},
+		{"1:a", new(int), "cannot unmarshal byte string at offset 0 into Go value of type int"},
+		{"d4:namei1ee", new(testInfo), `dictionary key "name": cannot unmarshal integer at offset 7 into Go value of type string`},
+		{"i1e", testInfo{}, "cannot unmarshal into non-pointer bencode.testInfo"},
+	}
+	for _, tt := range tests {
+		if err := Unmarshal([]byte(tt.in), tt.v); err == nil || err.Error() != tt.want {
+			t.Errorf("Unmarshal(%q, %T) error = %v, want %q", tt.in, tt.v, err, tt.want)
+		}
+	}
+
+	var n int
+	if err := UnmarshalStrict([]byte("i01e"), &n); err == nil {
+		t.Error("UnmarshalStrict() of a non-canonical integer succeeded")
+	}
+	if err := UnmarshalStrict([]byte("i1ei2e"), &n); err == nil {
+		t.Error("UnmarshalStrict() with trailing data succeeded")
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	length := int64(1) << 40
+	in := testInfo{
+		Name:   "dir",
+		Length: &length,
+		Files:  []testFile{{Length: 3, Path: []string{"a", "b"}}, {Length: 0, Path: []string{"c"}}},
+		Raw:    RawMessage("le"),
+		NoTag:  -1,
+	}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if err := Check(b); err != nil {
+		t.Errorf("Marshal() output is not canonical: %v", err)
+	}
+	var out testInfo
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+}
+
+func TestMarshalBigInt(t *testing.T) {
+	type counters struct {
+		Total big.Int  `bencode:"total"`
+		Max   *big.Int `bencode:"max,omitempty"`
+		Small int64    `bencode:"small"`
+	}
+	in := counters{Total: *bigInt("-123456789012345678901234567890"), Max: bigInt("18446744073709551616"), Small: 1}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	want := "d3:maxi18446744073709551616e5:smalli1e5:totali-123456789012345678901234567890ee"
+	if string(b) != want {
+		t.Errorf("Marshal() = %q, want %q", b, want)
+	}
+	var out counters
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if out.Total.Cmp(&in.Total) != 0 || out.Max.Cmp(in.Max) != 0 || out.Small != 1 {
+		t.Errorf("Unmarshal() = %+v, want %+v", out, in)
+	}
+
+	var small int64
+	if err := Unmarshal([]byte("i18446744073709551616e"), &small); err == nil || err.Error() != "integer 18446744073709551616 at offset 0 overflows int64" {
+		t.Errorf("Unmarshal() of a big integer into int64 error = %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Unmarshal decodes the first bencoded value in data into the value pointed
+// to by v, following the rules of Marshal in reverse.
+//
+// Byte strings decode into strings and byte slices, integers into any
+// integer type that can hold them, big.Int and booleans, lists into slices
+// and dictionaries into maps with string keys and structs. Dictionary
+// entries without a matching struct field are skipped. A RawMessage
+// receives the value exactly as it is encoded, and an empty interface
+// receives the value as returned by Decode. Pointers are allocated as
+// needed.
+func Unmarshal(data []byte, v interface{}) error {
+	return NewDecoder(bytes.NewReader(data)).DecodeInto(v)
+}
+
+// UnmarshalStrict is like Unmarshal, but data must hold exactly one value
+// in the canonical encoding, as checked by Check.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	d := NewDecoder(bytes.NewReader(data))
+	d.SetStrict(true)
+	if err := d.DecodeInto(v); err != nil {
+		return d.positioned(err)
+	}
+	return d.end()
+}
+
+func (d *Decoder) unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cannot unmarshal into non-pointer %T", v)
+	}
+	return d.decodeValue(rv.Elem())
+}
+
+// kind returns the name of the kind of the next value.
+func (d *Decoder) kind() (string, error) {
+	c, err := d.r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	switch c[0] {
+	case 'i':
+		return "integer", nil
+	case 'l':
+		return "list", nil
+	case 'd':
+		return "dictionary", nil
+	}
+	return "byte string", nil
+}
+
+func (d *Decoder) decodeValue(v reflect.Value) error {
+	if v.Type() == rawMessageType {
+		raw, err := d.decodeRaw()
+		if err != nil {
+			return err
+		}
+		v.SetBytes(raw)
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(v.Elem())
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		val, err := d.decode()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(val))
+		return nil
+	}
+
+	kind, err := d.kind()
+	if err != nil {
+		return err
+	}
+	if v.Type() == bigIntType && kind == "integer" {
+		off := d.off
+		digits, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		n, err := parseBigInt(digits, off)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(n).Elem())
+		return nil
+	}
+	mismatch := func() error {
+		return fmt.Errorf("cannot unmarshal %s at offset %d into Go value of type %s", kind, d.off, v.Type())
+	}
+	switch kind {
+	case "byte string":
+		switch {
+		case v.Kind() == reflect.String:
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		default:
+			return mismatch()
+		}
+		str, err := d.decodeString()
+		if err != nil {
+			return err
+		}
+		if v.Kind() == reflect.String {
+			v.SetString(string(str))
+		} else {
+			v.SetBytes(str)
+		}
+		return nil
+
+	case "integer":
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		case reflect.Bool:
+		default:
+			return mismatch()
+		}
+		off := d.off
+		digits, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(digits, 10, 64)
+			if err != nil || v.OverflowInt(n) {
+				return intError(err, digits, off, v.Type())
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(digits, 10, 64)
+			if err != nil || v.OverflowUint(n) {
+				return intError(err, digits, off, v.Type())
+			}
+			v.SetUint(n)
+		case reflect.Bool:
+			n, err := strconv.ParseInt(digits, 10, 64)
+			if err != nil {
+				return intError(err, digits, off, v.Type())
+			}
+			v.SetBool(n != 0)
+		}
+		return nil
+
+	case "list":
+		if v.Kind() != reflect.Slice {
+			return mismatch()
+		}
+		d.readByte()
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for {
+			if c, err := d.r.Peek(1); err != nil {
+				return err
+			} else if c[0] == 'e' {
+				d.readByte()
+				break
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decodeValue(elem); err != nil {
+				return err
+			}
+			list = reflect.Append(list, elem)
+		}
+		v.Set(list)
+		return nil
+
+	case "dictionary":
+		var fields map[string]field
+		switch {
+		case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+		case v.Kind() == reflect.Struct:
+			fields = make(map[string]field)
+			for _, f := range structFields(v.Type()) {
+				fields[f.name] = f
+			}
+		default:
+			return mismatch()
+		}
+		d.readByte()
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		var key []byte
+		for {
+			if c, err := d.r.Peek(1); err != nil {
+				return err
+			} else if c[0] == 'e' {
+				d.readByte()
+				return nil
+			}
+			var err error
+			key, err = d.dictKey(key)
+			if err != nil {
+				return err
+			}
+
+			if v.Kind() == reflect.Map {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := d.decodeValue(elem); err != nil {
+					return err
+				}
+				v.SetMapIndex(reflect.ValueOf(string(key)).Convert(v.Type().Key()), elem)
+				continue
+			}
+			f, ok := fields[string(key)]
+			if !ok {
+				if _, err := d.decode(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decodeValue(v.Field(f.index)); err != nil {
+				return fmt.Errorf("dictionary key %q: %w", key, err)
+			}
+		}
+	}
+	return mismatch()
+}
+
+// intError returns the error for integer digits at offset off that could
+// not be parsed into a value of type t.
+func intError(err error, digits string, off int64, t reflect.Type) error {
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return syntaxError(off, "invalid integer %q", digits)
+	}
+	return fmt.Errorf("integer %s at offset %d overflows %s", digits, off, t)
+}
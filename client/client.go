@@ -0,0 +1,492 @@
+// Package client downloads torrents from peers found through trackers or
+// magnet links.
+//
+// A Client is a session that manages any number of torrents with one peer
+// ID, one listening port and shared connection and bandwidth limits:
+//
+//	c, err := client.NewClient(client.Config{DownloadDir: "/srv/artifacts"})
+//	...
+//	t, err := c.AddTorrentFile("release.torrent")
+//	...
+//	t.Subscribe(func(e client.Event) { log.Println(e.Type) })
+//	t.Start()
+//	err = t.Wait(ctx)
+//
+// The lower level functions Connect, ConnectMagnet, FetchMetadata and
+// DownloadPiece work on a single peer connection.
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/magnet"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/peerwire"
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+)
+
+// DefaultPort is the port announced to trackers.
+const DefaultPort = 6881
+
+// Config configures a Client. The zero value is a valid configuration.
+type Config struct {
+	// PeerID is the 20-byte ID sent to trackers and peers. A random ID
+	// starting with PeerIDPrefix is generated if it is empty.
+	PeerID       string
+	PeerIDPrefix string
+	// Port is the port announced to trackers. DefaultPort is used if it
+	// is zero.
+	Port int
+	// DownloadDir is the directory torrents are saved in, unless an
+	// output path is set on the torrent.
+	DownloadDir string
+	// Limits are the initial limits of the client.
+	Limits Limits
+	// ListenAddr is the address the client accepts incoming peer
+	// connections on, such as ":6881". Incoming connections are not
+	// accepted if it is empty. If Port is zero, the listening port is
+	// announced.
+	ListenAddr string
+	// BlockSize is the size of the blocks pieces are requested in. It is
+	// at most client.BlockSize, which is also used if it is zero.
+	BlockSize int
+	// TrackerTimeout bounds every announce. Announces are only bounded by
+	// the torrent's lifetime if it is zero.
+	TrackerTimeout time.Duration
+}
+
+// Limits are the connection and bandwidth limits of a Client. They can be
+// changed while the client runs. Rates are in bytes per second and zero
+// means no limit.
+type Limits struct {
+	// MaxPeers limits the number of peers each torrent connects to.
+	MaxPeers int
+	// MaxConnections limits the number of peer connections of all
+	// torrents together, incoming and outgoing. Lowering it does not close
+	// connections.
+	MaxConnections int
+	// DownloadRateLimit and UploadRateLimit limit the rates of all
+	// torrents together.
+	DownloadRateLimit int
+	UploadRateLimit   int
+	// PeerDownloadRateLimit and PeerUploadRateLimit limit the rates of
+	// every peer connection.
+	PeerDownloadRateLimit int
+	PeerUploadRateLimit   int
+	// AltDownloadRateLimit and AltUploadRateLimit replace
+	// DownloadRateLimit and UploadRateLimit while AltSchedule is active or
+	// AltEnabled is set.
+	AltDownloadRateLimit int
+	AltUploadRateLimit   int
+	AltSchedule          *ratelimit.Schedule
+	AltEnabled           bool
+}
+
+// altActive reports whether the alternative rate limits apply at t.
+func (l Limits) altActive(t time.Time) bool {
+	return l.AltEnabled || (l.AltSchedule != nil && l.AltSchedule.Active(t))
+}
+
+// rates returns the session-wide download and upload rate limits that
+// apply at t.
+func (l Limits) rates(t time.Time) (download, upload int) {
+	if l.altActive(t) {
+		return l.AltDownloadRateLimit, l.AltUploadRateLimit
+	}
+	return l.DownloadRateLimit, l.UploadRateLimit
+}
+
+// SessionStats is a snapshot of the activity of a Client.
+type SessionStats struct {
+	Torrents    int
+	Connections int
+	// DownloadRate and UploadRate are the rates of all torrents together,
+	// in bytes per second.
+	DownloadRate    int
+	UploadRate      int
+	BytesDownloaded int64
+	BytesUploaded   int64
+	// AltLimitsActive is set while the alternative rate limits apply.
+	AltLimitsActive bool
+}
+
+// peerSetupTimeout bounds the exchange that makes a new connection ready to
+// serve pieces, after the handshake.
+const peerSetupTimeout = 30 * time.Second
+
+// scheduleInterval is how often the client checks whether the alternative
+// rate limits start or stop applying.
+const scheduleInterval = 30 * time.Second
+
+// Client manages a set of torrents.
+type Client struct {
+	config        Config
+	ctx           context.Context
+	cancel        context.CancelFunc
+	listener      net.Listener
+	wg            sync.WaitGroup
+	downloadLimit *ratelimit.Limiter
+	uploadLimit   *ratelimit.Limiter
+
+	mu       sync.Mutex
+	torrents map[string]*Torrent
+	nextSeq  int
+	limits   Limits
+	conns    int
+	subs     subscribers
+}
+
+// NewClient returns a client for config. If config.ListenAddr is set, the
+// client starts accepting incoming connections.
+func NewClient(config Config) (*Client, error) {
+	if config.PeerID == "" {
+		id, err := GenPeerIDWithPrefix(config.PeerIDPrefix)
+		if err != nil {
+			return nil, err
+		}
+		config.PeerID = id
+	}
+	if len(config.PeerID) != 20 {
+		return nil, fmt.Errorf("peer ID must be 20 bytes, got %d", len(config.PeerID))
+	}
+	if config.BlockSize == 0 {
+		config.BlockSize = BlockSize
+	}
+	if config.BlockSize < 0 || config.BlockSize > BlockSize {
+		return nil, fmt.Errorf("invalid block size %d, must be 1 to %d bytes", config.BlockSize, BlockSize)
+	}
+
+	download, upload := config.Limits.rates(time.Now())
+	c := &Client{
+		torrents:      make(map[string]*Torrent),
+		downloadLimit: ratelimit.New(download),
+		uploadLimit:   ratelimit.New(upload),
+		limits:        config.Limits,
+	}
+	if config.ListenAddr != "" {
+		l, err := net.Listen("tcp", config.ListenAddr)
+		if err != nil {
+			return nil, err
+		}
+		c.listener = l
+		if config.Port == 0 {
+			config.Port = l.Addr().(*net.TCPAddr).Port
+		}
+	}
+	if config.Port == 0 {
+		config.Port = DefaultPort
+	}
+	c.config = config
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	if c.listener != nil {
+		c.wg.Add(1)
+		go c.acceptLoop()
+	}
+	c.wg.Add(1)
+	go c.scheduleLoop()
+	return c, nil
+}
+
+// Config returns the configuration of the client, with defaults filled in.
+// Its Limits are the ones the client was created with; see Limits for the
+// current ones.
+func (c *Client) Config() Config {
+	return c.config
+}
+
+// PeerID returns the peer ID of the client.
+func (c *Client) PeerID() string {
+	return c.config.PeerID
+}
+
+// Addr returns the address the client accepts peer connections on, or nil
+// if it does not listen.
+func (c *Client) Addr() net.Addr {
+	if c.listener == nil {
+		return nil
+	}
+	return c.listener.Addr()
+}
+
+// AddTorrentFile adds the torrent described by the named .torrent file. The
+// torrent is not started.
+func (c *Client) AddTorrentFile(path string) (*Torrent, error) {
+	info, err := metainfo.FromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.AddTorrentInfo(info)
+}
+
+// AddTorrentInfo adds the torrent described by info. The torrent is not
+// started.
+func (c *Client) AddTorrentInfo(info *metainfo.TorrentInfo) (*Torrent, error) {
+	return c.add(newTorrent(c, info.InfoHash, info.TrackerURL, info))
+}
+
+// AddMagnet adds the torrent identified by a magnet link. Its metadata is
+// downloaded from peers once the torrent is started, including the peers
+// given by the link's "x.pe" parameters.
+func (c *Client) AddMagnet(link string) (*Torrent, error) {
+	mag, err := magnet.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	t := newTorrent(c, mag.InfoHash, mag.Tracker(), nil)
+	t.AddPeers(mag.Peers...)
+	return c.add(t)
+}
+
+// Torrent returns the torrent with the given info hash, or nil.
+func (c *Client) Torrent(infoHash []byte) *Torrent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.torrents[hex.EncodeToString(infoHash)]
+}
+
+func (c *Client) add(t *Torrent) (*Torrent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := hex.EncodeToString(t.infoHash)
+	if _, ok := c.torrents[key]; ok {
+		return nil, fmt.Errorf("torrent %s already added", key)
+	}
+	c.nextSeq++
+	t.seq = c.nextSeq
+	c.torrents[key] = t
+	return t, nil
+}
+
+// Torrents returns every torrent of the client in the order they were
+// added.
+func (c *Client) Torrents() []*Torrent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	torrents := make([]*Torrent, 0, len(c.torrents))
+	for _, t := range c.torrents {
+		torrents = append(torrents, t)
+	}
+	sort.Slice(torrents, func(i, j int) bool { return torrents[i].seq < torrents[j].seq })
+	return torrents
+}
+
+// Remove stops the torrent with the given info hash and removes it from
+// the client. If deleteData is set, the files it saved are deleted too.
+func (c *Client) Remove(infoHash []byte, deleteData bool) error {
+	t := c.Torrent(infoHash)
+	if t == nil {
+		return fmt.Errorf("torrent %x not found", infoHash)
+	}
+	t.mu.Lock()
+	out := t.out
+	t.mu.Unlock()
+
+	t.Stop()
+	c.mu.Lock()
+	delete(c.torrents, hex.EncodeToString(infoHash))
+	c.mu.Unlock()
+	if deleteData && out != nil {
+		return out.Delete()
+	}
+	return nil
+}
+
+// Limits returns the current limits of the client.
+func (c *Client) Limits() Limits {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limits
+}
+
+// SetLimits changes the limits of the client. Rate limits apply to
+// existing connections immediately, connection limits to connections made
+// after the call.
+func (c *Client) SetLimits(limits Limits) {
+	c.mu.Lock()
+	c.limits = limits
+	c.mu.Unlock()
+	c.applyLimits()
+}
+
+// applyLimits updates the rates of the session and peer limiters.
+func (c *Client) applyLimits() {
+	limits := c.Limits()
+	download, upload := limits.rates(time.Now())
+	c.downloadLimit.SetRate(download)
+	c.uploadLimit.SetRate(upload)
+	for _, t := range c.Torrents() {
+		t.setPeerLimits(limits.PeerDownloadRateLimit, limits.PeerUploadRateLimit)
+	}
+}
+
+// scheduleLoop switches between the normal and alternative rate limits as
+// their schedule says, until the client is closed.
+func (c *Client) scheduleLoop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.applyLimits()
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Stats returns a snapshot of the activity of the client.
+func (c *Client) Stats() SessionStats {
+	torrents := c.Torrents()
+	c.mu.Lock()
+	s := SessionStats{
+		Torrents:        len(torrents),
+		Connections:     c.conns,
+		AltLimitsActive: c.limits.altActive(time.Now()),
+	}
+	c.mu.Unlock()
+	for _, t := range torrents {
+		ts := t.Stats()
+		s.DownloadRate += ts.DownloadRate
+		s.UploadRate += ts.UploadRate
+		s.BytesDownloaded += ts.BytesDownloaded
+		s.BytesUploaded += ts.BytesUploaded
+	}
+	return s
+}
+
+// Subscribe registers fn to be called for events of every torrent of the
+// client. Callbacks run on the goroutine that produced the event and must
+// not block. The returned function removes the subscription.
+func (c *Client) Subscribe(fn func(Event)) func() {
+	return c.subs.add(fn)
+}
+
+// Close stops every torrent of the client and stops accepting
+// connections.
+func (c *Client) Close() error {
+	var err error
+	if c.listener != nil {
+		err = c.listener.Close()
+	}
+	for _, t := range c.Torrents() {
+		t.Stop()
+	}
+	c.cancel()
+	c.wg.Wait()
+	return err
+}
+
+// acquireSlot reserves one of the client's connection slots. It returns
+// false if all slots are taken.
+func (c *Client) acquireSlot() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if max := c.limits.MaxConnections; max > 0 && c.conns >= max {
+		return false
+	}
+	c.conns++
+	return true
+}
+
+func (c *Client) releaseSlot() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns--
+}
+
+func (c *Client) acceptLoop() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			if c.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if !c.acquireSlot() {
+			conn.Close()
+			continue
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if !c.handleIncoming(conn) {
+				c.releaseSlot()
+			}
+		}()
+	}
+}
+
+// handleIncoming performs the handshake on an incoming connection and hands
+// it to the torrent it asks for. It returns false if the connection was
+// rejected.
+func (c *Client) handleIncoming(rawConn net.Conn) bool {
+	conn, err := peerwire.Accept(c.ctx, rawConn, c.config.PeerID, func(infoHash []byte) bool {
+		return c.Torrent(infoHash) != nil
+	})
+	if err != nil {
+		return false
+	}
+
+	t := c.Torrent(conn.InfoHash)
+	if t == nil || !t.offer(rawConn.RemoteAddr().String(), conn) {
+		conn.Close()
+		return false
+	}
+	return true
+}
+
+// GenPeerID returns a random 20-byte peer ID.
+func GenPeerID() string {
+	barray := make([]byte, 10)
+	rand.Read(barray)
+	return hex.EncodeToString(barray)
+}
+
+// GenPeerIDWithPrefix returns a random 20-byte peer ID starting with
+// prefix, such as the "-XX0001-" client identifier of the Azureus style.
+func GenPeerIDWithPrefix(prefix string) (string, error) {
+	if len(prefix) > 20 {
+		return "", fmt.Errorf("peer ID prefix %q is longer than 20 bytes", prefix)
+	}
+	return prefix + GenPeerID()[len(prefix):], nil
+}
+
+// Connect opens a connection to a peer that is ready to serve pieces: it
+// performs the handshake, waits for the peer's bitfield and asks to be
+// unchoked.
+func Connect(ctx context.Context, address string, peerID string, infoHash []byte) (*peerwire.Conn, error) {
+	conn, err := peerwire.Dial(ctx, address, peerID, infoHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareConn(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// prepareConn waits for the peer's bitfield and asks to be unchoked.
+func prepareConn(conn *peerwire.Conn) error {
+	conn.SetTimeout(peerSetupTimeout)
+	// assume all peers have all the pieces
+	if _, err := conn.ReadBitfield(); err != nil {
+		return err
+	}
+	if err := conn.SendInterested(); err != nil {
+		return err
+	}
+	return conn.SetTimeout(0)
+}
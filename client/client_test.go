@@ -0,0 +1,30 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+)
+
+func TestLimitsRates(t *testing.T) {
+	office := &ratelimit.Schedule{Start: 9 * time.Hour, End: 18 * time.Hour}
+	noon := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+	night := time.Date(2026, 10, 19, 23, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		limits   Limits
+		t        time.Time
+		down, up int
+	}{
+		{"no alternative", Limits{DownloadRateLimit: 10, UploadRateLimit: 20}, noon, 10, 20},
+		{"enabled", Limits{DownloadRateLimit: 10, UploadRateLimit: 20, AltDownloadRateLimit: 1, AltUploadRateLimit: 2, AltEnabled: true}, night, 1, 2},
+		{"in the schedule", Limits{DownloadRateLimit: 10, AltDownloadRateLimit: 1, AltSchedule: office}, noon, 1, 0},
+		{"out of the schedule", Limits{DownloadRateLimit: 10, AltDownloadRateLimit: 1, AltSchedule: office}, night, 10, 0},
+	}
+	for _, tt := range tests {
+		if down, up := tt.limits.rates(tt.t); down != tt.down || up != tt.up {
+			t.Errorf("%s: rates() = %d, %d, want %d, %d", tt.name, down, up, tt.down, tt.up)
+		}
+	}
+}
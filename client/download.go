@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/peerwire"
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+)
+
+// BlockSize is the default size of the blocks pieces are requested in.
+// Most peers refuse requests for larger blocks.
+const BlockSize = 1 << 14
+
+// DownloadPiece downloads piece pieceIdx of t from conn. The data is not
+// verified against the piece hash.
+func DownloadPiece(ctx context.Context, conn *peerwire.Conn, t *metainfo.TorrentInfo, pieceIdx int) ([]byte, error) {
+	return downloadPiece(ctx, conn, t, pieceIdx, BlockSize, nil, nil)
+}
+
+// DownloadPieceBlocks is DownloadPiece with blocks of blockSize bytes.
+// blockSize must not be larger than BlockSize.
+func DownloadPieceBlocks(ctx context.Context, conn *peerwire.Conn, t *metainfo.TorrentInfo, pieceIdx, blockSize int) ([]byte, error) {
+	if blockSize <= 0 || blockSize > BlockSize {
+		return nil, fmt.Errorf("invalid block size %d, must be 1 to %d bytes", blockSize, BlockSize)
+	}
+	return downloadPiece(ctx, conn, t, pieceIdx, blockSize, nil, nil)
+}
+
+// downloadPiece is DownloadPiece with blocks of blockSize bytes, and every
+// block request throttled by limits. If received is not nil, it is called
+// with the length of every block that arrives.
+func downloadPiece(ctx context.Context, conn *peerwire.Conn, t *metainfo.TorrentInfo, pieceIdx int, blockSize int, limits []*ratelimit.Limiter, received func(int)) ([]byte, error) {
+	if pieceIdx < 0 || pieceIdx >= t.NumPieces() {
+		return nil, fmt.Errorf("piece %d out of range", pieceIdx)
+	}
+	pieceSize := t.PieceSize(pieceIdx)
+	pieceData := make([]byte, 0, pieceSize)
+
+	for blockIdx := 0; ; blockIdx++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		blockLength, last := calculateBlockLength(pieceSize, blockSize, blockIdx)
+		if err := ratelimit.WaitAll(ctx, blockLength, limits...); err != nil {
+			return nil, err
+		}
+		block, err := conn.RequestBlock(pieceIdx, blockIdx*blockSize, blockLength)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if received != nil {
+			received(len(block))
+		}
+		pieceData = append(pieceData, block...)
+		if last {
+			break
+		}
+	}
+	return pieceData, nil
+}
+
+// calculateBlockLength returns the length of block blockIndex of a piece of
+// pieceSize bytes requested in blocks of at most maxBlockLength bytes, and
+// whether it is the last block of the piece.
+func calculateBlockLength(pieceSize, maxBlockLength, blockIndex int) (int, bool) {
+	offset := blockIndex * maxBlockLength
+	if offset+maxBlockLength >= pieceSize {
+		return pieceSize - offset, true
+	}
+	return maxBlockLength, false
+}
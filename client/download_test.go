@@ -0,0 +1,30 @@
+package client
+
+import "testing"
+
+func TestCalculateBlockLength(t *testing.T) {
+	tests := []struct {
+		name       string
+		pieceSize  int
+		blockSize  int
+		blockIndex int
+		want       int
+		last       bool
+	}{
+		{"first of many", 262144, 16384, 0, 16384, false},
+		{"last exact", 262144, 16384, 15, 16384, true},
+		{"uneven first", 262144, 10000, 0, 10000, false},
+		{"uneven middle", 262144, 10000, 25, 10000, false},
+		{"uneven last", 262144, 10000, 26, 2144, true},
+		{"short last piece", 5000, 16384, 0, 5000, true},
+		{"short last piece uneven", 25000, 10000, 2, 5000, true},
+		{"single byte blocks", 3, 1, 2, 1, true},
+	}
+	for _, tt := range tests {
+		got, last := calculateBlockLength(tt.pieceSize, tt.blockSize, tt.blockIndex)
+		if got != tt.want || last != tt.last {
+			t.Errorf("%s: calculateBlockLength(%d, %d, %d) = %d, %v, want %d, %v",
+				tt.name, tt.pieceSize, tt.blockSize, tt.blockIndex, got, last, tt.want, tt.last)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package client
+
+import "sync"
+
+// EventType identifies the kind of an Event.
+type EventType int
+
+const (
+	// EventPieceVerified is sent when a piece passed its hash check and was
+	// written to disk. Event.Piece holds its index.
+	EventPieceVerified EventType = iota
+	// EventPeerConnected is sent when a peer connection is ready to serve
+	// pieces. Event.Peer holds the peer address.
+	EventPeerConnected
+	// EventMetadataReceived is sent when the info dictionary of a torrent
+	// added from a magnet link has been downloaded.
+	EventMetadataReceived
+	// EventTrackerError is sent when an announce fails. Event.Err holds the
+	// error.
+	EventTrackerError
+	// EventCompleted is sent when every piece of a torrent is on disk.
+	EventCompleted
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventPieceVerified:
+		return "piece verified"
+	case EventPeerConnected:
+		return "peer connected"
+	case EventMetadataReceived:
+		return "metadata received"
+	case EventTrackerError:
+		return "tracker error"
+	case EventCompleted:
+		return "completed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something that happened to a torrent.
+type Event struct {
+	Type    EventType
+	Torrent *Torrent
+	Piece   int
+	Peer    string
+	Err     error
+}
+
+// subscribers is a set of event callbacks.
+type subscribers struct {
+	mu   sync.Mutex
+	next int
+	fns  map[int]func(Event)
+}
+
+func (s *subscribers) add(fn func(Event)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fns == nil {
+		s.fns = make(map[int]func(Event))
+	}
+	id := s.next
+	s.next++
+	s.fns[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.fns, id)
+	}
+}
+
+func (s *subscribers) emit(e Event) {
+	s.mu.Lock()
+	fns := make([]func(Event), 0, len(s.fns))
+	for _, fn := range s.fns {
+		fns = append(fns, fn)
+	}
+	s.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(e)
+	}
+}
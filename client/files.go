@@ -0,0 +1,212 @@
+package client
+
+import (
+	"fmt"
+	"path"
+)
+
+// Priority is the download priority of a file. Pieces of files with a
+// higher priority are downloaded first, and files that are skipped are not
+// downloaded at all.
+type Priority int
+
+const (
+	PrioritySkip   Priority = -2
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PrioritySkip:
+		return "skip"
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes p as its name.
+func (p Priority) MarshalText() ([]byte, error) {
+	if p < PrioritySkip || p > PriorityHigh {
+		return nil, fmt.Errorf("invalid priority %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a priority name as returned by String.
+func (p *Priority) UnmarshalText(text []byte) error {
+	for q := PrioritySkip; q <= PriorityHigh; q++ {
+		if q.String() == string(text) {
+			*p = q
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown priority %q", text)
+}
+
+// FileStats is a snapshot of the progress of one file of a torrent.
+type FileStats struct {
+	// Path is the path of the file within the torrent, with elements
+	// separated by slashes.
+	Path     string
+	Length   int64
+	Priority Priority
+	// BytesCompleted is the part of the file covered by verified pieces.
+	BytesCompleted int64
+}
+
+// Files returns the progress of every file of the torrent, or nil while
+// the metadata of a magnet link is unknown.
+func (t *Torrent) Files() []FileStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.info == nil {
+		return nil
+	}
+	files := make([]FileStats, len(t.info.Files))
+	for i, f := range t.info.Files {
+		files[i] = FileStats{
+			Path:     path.Join(f.Path...),
+			Length:   f.Length,
+			Priority: t.filePriority(i),
+		}
+	}
+	for i := range t.info.NumPieces() {
+		if !t.isVerified(i) {
+			continue
+		}
+		start := t.info.PieceOffset(i)
+		end := start + int64(t.info.PieceSize(i))
+		for _, fi := range t.info.FilesInPiece(i) {
+			f := t.info.Files[fi]
+			files[fi].BytesCompleted += min(end, f.Offset+f.Length) - max(start, f.Offset)
+		}
+	}
+	return files
+}
+
+// SetFilePriorities sets the priority of the files with the given indexes,
+// as listed by Files. It takes effect immediately on a running download. A
+// completed torrent starts again if a skipped file is no longer skipped.
+func (t *Torrent) SetFilePriorities(files []int, p Priority) error {
+	if p < PrioritySkip || p > PriorityHigh {
+		return fmt.Errorf("invalid priority %d", int(p))
+	}
+
+	t.mu.Lock()
+	if t.info == nil {
+		t.mu.Unlock()
+		return fmt.Errorf("metadata of torrent %x is not known yet", t.infoHash)
+	}
+	for _, f := range files {
+		if f < 0 || f >= len(t.info.Files) {
+			t.mu.Unlock()
+			return fmt.Errorf("file index %d out of range", f)
+		}
+	}
+	if t.priorities == nil {
+		t.priorities = make([]Priority, len(t.info.Files))
+	}
+	for _, f := range files {
+		if t.priorities[f] == PrioritySkip && p != PrioritySkip {
+			t.unskip(f)
+		}
+		t.priorities[f] = p
+		if t.out != nil {
+			t.out.Skip(f, p == PrioritySkip)
+		}
+	}
+
+	restart := false
+	if q := t.queue; q != nil {
+		priorities := make([]int, t.info.NumPieces())
+		for i := range priorities {
+			prio := t.piecePriority(i)
+			priorities[i] = int(prio)
+			if t.isVerified(i) {
+				continue
+			}
+			if prio == PrioritySkip {
+				q.remove(i)
+			} else {
+				// unskip may have invalidated pieces that the queue
+				// already counts as done
+				q.requeue(i)
+			}
+		}
+		q.prioritize(func(i int) int { return priorities[i] })
+	} else {
+		restart = t.state == StateCompleted && !t.wantedDone()
+	}
+	t.mu.Unlock()
+
+	if restart {
+		return t.Start()
+	}
+	return nil
+}
+
+// unskip marks the pieces at the edges of file f as missing again if they
+// are shared with other files: they may have been downloaded for a
+// neighbouring file while f was skipped, in which case the part of their
+// data that belongs to f was dropped. It must be called with t.mu held.
+func (t *Torrent) unskip(f int) {
+	file := t.info.Files[f]
+	if file.Length == 0 || t.verified == nil {
+		return
+	}
+	first := int(file.Offset / int64(t.info.PieceLength))
+	last := int((file.Offset + file.Length - 1) / int64(t.info.PieceLength))
+	for _, i := range []int{first, last} {
+		if t.verified[i] && len(t.info.FilesInPiece(i)) > 1 {
+			t.verified[i] = false
+			t.numVerified--
+		}
+	}
+}
+
+// filePriority returns the priority of file i. It must be called with t.mu
+// held.
+func (t *Torrent) filePriority(i int) Priority {
+	if t.priorities == nil {
+		return PriorityNormal
+	}
+	return t.priorities[i]
+}
+
+// piecePriority returns the highest priority of the files that piece i
+// overlaps. It must be called with t.mu held.
+func (t *Torrent) piecePriority(i int) Priority {
+	prio := PrioritySkip
+	for _, f := range t.info.FilesInPiece(i) {
+		prio = max(prio, t.filePriority(f))
+	}
+	return prio
+}
+
+// isVerified reports whether piece i is on disk. It must be called with
+// t.mu held.
+func (t *Torrent) isVerified(i int) bool {
+	return i < len(t.verified) && t.verified[i]
+}
+
+// wantedDone reports whether every piece of the files that are not skipped
+// is on disk. It must be called with t.mu held.
+func (t *Torrent) wantedDone() bool {
+	if t.info == nil {
+		return false
+	}
+	for i := range t.info.NumPieces() {
+		if !t.isVerified(i) && t.piecePriority(i) != PrioritySkip {
+			return false
+		}
+	}
+	return true
+}
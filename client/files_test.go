@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+// TestUnskipRequeuesEdgePieces skips the middle file of a torrent whose
+// pieces are all downloaded, unskips it and checks that the pieces it
+// shares with its neighbours are downloaded again.
+func TestUnskipRequeuesEdgePieces(t *testing.T) {
+	// Pieces of 4 bytes over files of 6, 4 and 6 bytes: file 1 covers the
+	// end of piece 1 and the start of piece 2.
+	info := &metainfo.TorrentInfo{
+		Name:        "test",
+		FileLength:  16,
+		MultiFile:   true,
+		PieceLength: 4,
+		PieceHashes: make([]string, 4),
+		Files: []metainfo.File{
+			{Path: []string{"a"}, Length: 6, Offset: 0},
+			{Path: []string{"b"}, Length: 4, Offset: 6},
+			{Path: []string{"c"}, Length: 6, Offset: 10},
+		},
+	}
+	tor := newTorrent(nil, make([]byte, 20), "", info)
+	tor.verified = []bool{true, true, true, true}
+	tor.numVerified = 4
+	q := newWorkQueue()
+	for i := range 4 {
+		q.addItem(i)
+	}
+	for range 4 {
+		i, _ := q.next(context.Background())
+		q.complete(i)
+	}
+	tor.queue = q
+
+	if err := tor.SetFilePriorities([]int{1}, PrioritySkip); err != nil {
+		t.Fatal(err)
+	}
+	if err := tor.SetFilePriorities([]int{1}, PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := q.remaining(); got != 2 {
+		t.Fatalf("remaining() = %d, want 2", got)
+	}
+	var got []int
+	for range 2 {
+		i, ok := q.next(context.Background())
+		if !ok {
+			t.Fatal("next() returned no item")
+		}
+		got = append(got, i)
+	}
+	slices.Sort(got)
+	if want := []int{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("queued pieces = %v, want %v", got, want)
+	}
+	if tor.numVerified != 2 {
+		t.Errorf("numVerified = %d, want 2", tor.numVerified)
+	}
+}
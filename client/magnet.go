@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/peerwire"
+)
+
+// ConnectMagnet connects to a peer with the extension protocol enabled, as
+// needed to fetch metadata for a magnet link, and consumes its bitfield.
+func ConnectMagnet(ctx context.Context, address string, peerID string, infoHash []byte) (*peerwire.Conn, error) {
+	conn, err := peerwire.Dial(ctx, address, peerID, infoHash, peerwire.ExtensionReserved())
+	if err != nil {
+		return nil, err
+	}
+
+	// assume all peers have all the pieces so ignore the bitfield
+	conn.SetTimeout(peerSetupTimeout)
+	if _, err := conn.ReadBitfield(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetTimeout(0); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// FetchMetadata performs the extension handshake on a connection opened by
+// ConnectMagnet and downloads the torrent's info dictionary from the peer.
+func FetchMetadata(ctx context.Context, conn *peerwire.Conn) (*metainfo.TorrentInfo, error) {
+	conn.SetTimeout(peerSetupTimeout)
+	defer conn.SetTimeout(0)
+
+	handshake, err := conn.ExtensionHandshake()
+	if err != nil {
+		return nil, err
+	}
+	extID, err := peerwire.PeerMetadataExtensionID(handshake)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := conn.RequestMetadata(extID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return metainfo.FromBytes(metadata)
+}
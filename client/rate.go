@@ -0,0 +1,49 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// rateWindow is the number of seconds transfer rates are averaged over.
+const rateWindow = 5
+
+// rateMeter measures a transfer rate over the last rateWindow seconds.
+type rateMeter struct {
+	mu      sync.Mutex
+	buckets [rateWindow]int
+	// last is the second the newest bucket belongs to.
+	last int64
+}
+
+// add records n bytes transferred now.
+func (r *rateMeter) add(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().Unix()
+	r.advance(now)
+	r.buckets[now%rateWindow] += n
+}
+
+// rate returns the average rate in bytes per second.
+func (r *rateMeter) rate() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(time.Now().Unix())
+	total := 0
+	for _, n := range r.buckets {
+		total += n
+	}
+	return total / rateWindow
+}
+
+// advance clears the buckets of the seconds that passed since the last
+// call. It must be called with r.mu held.
+func (r *rateMeter) advance(now int64) {
+	for s := max(r.last+1, now-rateWindow+1); s <= now; s++ {
+		r.buckets[s%rateWindow] = 0
+	}
+	if now > r.last {
+		r.last = now
+	}
+}
@@ -0,0 +1,913 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/peerwire"
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+	"github.com/codecrafters-io/bittorrent-starter-go/storage"
+	"github.com/codecrafters-io/bittorrent-starter-go/tracker"
+)
+
+// stoppedAnnounceTimeout bounds the "stopped" announce sent by Stop.
+const stoppedAnnounceTimeout = 5 * time.Second
+
+// Re-announce intervals used when the tracker asks for none, and the lowest
+// interval we accept from a tracker.
+const (
+	defaultAnnounceInterval = 2 * time.Minute
+	minAnnounceInterval     = 30 * time.Second
+)
+
+// ErrStopped is returned by Wait for a torrent that was stopped before it
+// completed.
+var ErrStopped = errors.New("torrent stopped")
+
+// State is the lifecycle state of a torrent.
+type State int
+
+const (
+	StateStopped State = iota
+	StateDownloading
+	StatePaused
+	StateCompleted
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateDownloading:
+		return "downloading"
+	case StatePaused:
+		return "paused"
+	case StateCompleted:
+		return "completed"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Stats is a snapshot of a torrent's progress.
+type Stats struct {
+	State State
+	// Err is the error that made the torrent fail, if any.
+	Err error
+	// Peers is the number of connected peers.
+	Peers int
+	// PiecesTotal is zero until the metadata of a magnet link arrived.
+	PiecesTotal    int
+	PiecesVerified int
+	BytesTotal     int64
+	// BytesWanted is the size of the pieces of files that are not
+	// skipped.
+	BytesWanted int64
+	// BytesCompleted is the size of all verified pieces.
+	BytesCompleted int64
+	// BytesLeft is the size of the wanted pieces that are not verified
+	// yet.
+	BytesLeft int64
+	// BytesDownloaded counts piece data received from peers, including
+	// pieces that failed their hash check.
+	BytesDownloaded int64
+	// BytesUploaded counts piece data sent to peers.
+	BytesUploaded int64
+	// DownloadRate and UploadRate are in bytes per second.
+	DownloadRate int
+	UploadRate   int
+}
+
+// PeerStats is a snapshot of a connection to a peer.
+type PeerStats struct {
+	Addr string
+	// PeerID is the 20-byte ID the peer sent in its handshake.
+	PeerID string
+	// Incoming is set if the peer connected to us.
+	Incoming bool
+	// Downloaded counts piece data received from the peer and Uploaded
+	// piece data sent to it.
+	Downloaded int64
+	Uploaded   int64
+	// DownloadRate and UploadRate are in bytes per second.
+	DownloadRate int
+	UploadRate   int
+}
+
+// Torrent is a torrent managed by a Client.
+type Torrent struct {
+	client     *Client
+	infoHash   []byte
+	trackerURL string
+	seq        int
+	rate       rateMeter
+	uploadRate rateMeter
+	// downloadLimit and uploadLimit are the torrent's own rate limits.
+	downloadLimit *ratelimit.Limiter
+	uploadLimit   *ratelimit.Limiter
+
+	mu          sync.Mutex
+	info        *metainfo.TorrentInfo
+	outputPath  string
+	downloadDir string
+	out         *storage.Set
+	priorities  []Priority
+	verified    []bool
+	numVerified int
+	downloaded  int64
+	uploaded    int64
+	peers       map[string]*peer
+	// directPeers are peers to connect to besides those the tracker
+	// returns.
+	directPeers []string
+	queue       *workqueue
+	incoming    chan incomingPeer
+	announced   bool
+	state       State
+	err         error
+	cancel      context.CancelFunc
+	done        chan struct{}
+	changed     chan struct{}
+	subs        subscribers
+}
+
+// incomingPeer is a connection a peer opened to us.
+type incomingPeer struct {
+	addr string
+	conn *peerwire.Conn
+}
+
+// peer is a connection to a peer that serves pieces.
+type peer struct {
+	conn          *peerwire.Conn
+	incoming      bool
+	downloaded    int64
+	uploaded      int64
+	rate          rateMeter
+	uploadRate    rateMeter
+	downloadLimit *ratelimit.Limiter
+	uploadLimit   *ratelimit.Limiter
+}
+
+func newTorrent(c *Client, infoHash []byte, trackerURL string, info *metainfo.TorrentInfo) *Torrent {
+	if trackerURL == "~" {
+		trackerURL = ""
+	}
+	return &Torrent{
+		client:     c,
+		infoHash:   infoHash,
+		trackerURL: trackerURL,
+		info:       info,
+		peers:      make(map[string]*peer),
+		changed:    make(chan struct{}),
+
+		downloadLimit: ratelimit.New(0),
+		uploadLimit:   ratelimit.New(0),
+	}
+}
+
+// ID returns a number identifying the torrent within its client. IDs are
+// assigned in the order torrents are added, starting at 1.
+func (t *Torrent) ID() int {
+	return t.seq
+}
+
+// InfoHash returns the 20-byte info hash of the torrent.
+func (t *Torrent) InfoHash() []byte {
+	return t.infoHash
+}
+
+// Name returns the name from the torrent's metadata, or the hex info hash
+// while the metadata is unknown.
+func (t *Torrent) Name() string {
+	info := t.Info()
+	if info == nil || info.Name == "" || info.Name == "~" {
+		return hex.EncodeToString(t.infoHash)
+	}
+	return info.Name
+}
+
+// Info returns the torrent's metadata, or nil for a magnet link whose
+// metadata has not been downloaded yet.
+func (t *Torrent) Info() *metainfo.TorrentInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.info
+}
+
+// SetOutputPath sets the file the torrent is saved to, or the directory for
+// a torrent with several files. It must be called before Start. By default
+// the torrent is saved under its name in the client's download directory.
+func (t *Torrent) SetOutputPath(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outputPath = path
+}
+
+// AddPeers adds the "host:port" addresses of peers to connect to when the
+// torrent starts, besides those the tracker returns. With such peers the
+// torrent downloads even if it has no tracker or the tracker fails.
+func (t *Torrent) AddPeers(addrs ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, addr := range addrs {
+		if !slices.Contains(t.directPeers, addr) {
+			t.directPeers = append(t.directPeers, addr)
+		}
+	}
+}
+
+// SetDownloadDir sets the directory the torrent is saved in under its name,
+// instead of the client's download directory. It must be called before
+// Start and is ignored if an output path is set.
+func (t *Torrent) SetDownloadDir(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.downloadDir = dir
+}
+
+// Path returns the file the torrent is saved to, or the directory for a
+// torrent with several files.
+func (t *Torrent) Path() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.path()
+}
+
+// path returns the path the torrent is saved to. It must be called with
+// t.mu held.
+func (t *Torrent) path() string {
+	if t.outputPath != "" {
+		return t.outputPath
+	}
+	dir := t.downloadDir
+	if dir == "" {
+		dir = t.client.config.DownloadDir
+	}
+	name := hex.EncodeToString(t.infoHash)
+	// The name comes from the metainfo; one that would leave dir, such
+	// as "../x" or an absolute path, is never used.
+	if t.info != nil && t.info.Name != "" && t.info.Name != "~" && filepath.IsLocal(t.info.Name) {
+		name = t.info.Name
+	}
+	return filepath.Join(dir, name)
+}
+
+// SetRateLimits limits the download and upload rates of the torrent, in
+// bytes per second, on top of the limits of the client. Zero means no
+// limit. The limits apply immediately.
+func (t *Torrent) SetRateLimits(download, upload int) {
+	t.downloadLimit.SetRate(download)
+	t.uploadLimit.SetRate(upload)
+}
+
+// RateLimits returns the download and upload rate limits of the torrent.
+func (t *Torrent) RateLimits() (download, upload int) {
+	return t.downloadLimit.Rate(), t.uploadLimit.Rate()
+}
+
+// setPeerLimits changes the rate limits of every peer connection.
+func (t *Torrent) setPeerLimits(download, upload int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range t.peers {
+		p.downloadLimit.SetRate(download)
+		p.uploadLimit.SetRate(upload)
+	}
+}
+
+// Subscribe registers fn to be called for events of this torrent. Callbacks
+// run on the goroutine that produced the event and must not block. The
+// returned function removes the subscription.
+func (t *Torrent) Subscribe(fn func(Event)) func() {
+	return t.subs.add(fn)
+}
+
+func (t *Torrent) emit(e Event) {
+	e.Torrent = t
+	t.subs.emit(e)
+	t.client.subs.emit(e)
+}
+
+// broadcast wakes up every goroutine blocked in Wait. It must be called
+// with t.mu held.
+func (t *Torrent) broadcast() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// Start starts or resumes downloading in the background. Starting a torrent
+// that is downloading, or completed with every wanted piece on disk, does
+// nothing.
+func (t *Torrent) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch t.state {
+	case StateDownloading:
+		return nil
+	case StateCompleted:
+		if t.wantedDone() {
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.client.ctx)
+	t.cancel = cancel
+	t.done = make(chan struct{})
+	t.state = StateDownloading
+	t.err = nil
+	t.broadcast()
+	go t.run(ctx, cancel, t.done)
+	return nil
+}
+
+// Pause stops downloading but keeps the pieces downloaded so far, so a later
+// Start resumes where the torrent left off.
+func (t *Torrent) Pause() {
+	t.mu.Lock()
+	if t.state != StateDownloading {
+		t.mu.Unlock()
+		return
+	}
+	t.state = StatePaused
+	t.broadcast()
+	t.mu.Unlock()
+
+	t.halt()
+}
+
+// Stop stops the torrent, tells the tracker we left the swarm and discards
+// the data of an incomplete download. Files that were completed are kept.
+func (t *Torrent) Stop() {
+	t.mu.Lock()
+	prev := t.state
+	if prev == StateStopped {
+		t.mu.Unlock()
+		return
+	}
+	t.state = StateStopped
+	t.mu.Unlock()
+
+	t.halt()
+
+	t.mu.Lock()
+	if t.out != nil && prev != StateCompleted {
+		t.out.Abort()
+		t.out = nil
+		t.verified = nil
+		t.numVerified = 0
+	}
+	announced := t.announced
+	t.announced = false
+	t.broadcast()
+	t.mu.Unlock()
+
+	if announced {
+		ctx, cancel := context.WithTimeout(context.Background(), stoppedAnnounceTimeout)
+		defer cancel()
+		t.announce(ctx, tracker.EventStopped)
+	}
+}
+
+// halt cancels the running download, if any, and waits for it to exit.
+func (t *Torrent) halt() {
+	t.mu.Lock()
+	cancel, done := t.cancel, t.done
+	t.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Wait blocks until the torrent completed, failed or was stopped. It
+// returns nil once every wanted piece is on disk.
+func (t *Torrent) Wait(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		state, err, changed := t.state, t.err, t.changed
+		t.mu.Unlock()
+
+		switch state {
+		case StateCompleted:
+			return nil
+		case StateFailed:
+			return err
+		case StateStopped:
+			return ErrStopped
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Stats returns a snapshot of the torrent's progress.
+func (t *Torrent) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := Stats{
+		State:           t.state,
+		Err:             t.err,
+		Peers:           len(t.peers),
+		PiecesVerified:  t.numVerified,
+		BytesDownloaded: t.downloaded,
+		BytesUploaded:   t.uploaded,
+		DownloadRate:    t.rate.rate(),
+		UploadRate:      t.uploadRate.rate(),
+	}
+	if t.info != nil {
+		s.PiecesTotal = t.info.NumPieces()
+		s.BytesTotal = t.info.FileLength
+		for i := range s.PiecesTotal {
+			if t.isVerified(i) {
+				s.BytesCompleted += int64(t.info.PieceSize(i))
+			}
+			if t.piecePriority(i) != PrioritySkip {
+				s.BytesWanted += int64(t.info.PieceSize(i))
+				if !t.isVerified(i) {
+					s.BytesLeft += int64(t.info.PieceSize(i))
+				}
+			}
+		}
+	}
+	return s
+}
+
+// VerifiedPieces reports for every piece whether it passed its hash check.
+// It returns nil until the metadata of a magnet link arrived.
+func (t *Torrent) VerifiedPieces() []bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.info == nil {
+		return nil
+	}
+	pieces := make([]bool, t.info.NumPieces())
+	for i := range pieces {
+		pieces[i] = t.isVerified(i)
+	}
+	return pieces
+}
+
+// Peers returns a snapshot of the torrent's peer connections.
+func (t *Torrent) Peers() []PeerStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	peers := make([]PeerStats, 0, len(t.peers))
+	for addr, p := range t.peers {
+		peers = append(peers, PeerStats{
+			Addr:         addr,
+			PeerID:       string(p.conn.PeerID),
+			Incoming:     p.incoming,
+			Downloaded:   p.downloaded,
+			Uploaded:     p.uploaded,
+			DownloadRate: p.rate.rate(),
+			UploadRate:   p.uploadRate.rate(),
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Addr < peers[j].Addr })
+	return peers
+}
+
+func (t *Torrent) run(ctx context.Context, cancel context.CancelFunc, done chan struct{}) {
+	defer close(done)
+	defer cancel()
+
+	err := t.download(ctx)
+	t.closePeers()
+	if ctx.Err() != nil {
+		// paused or stopped, the caller already set the state
+		return
+	}
+
+	t.mu.Lock()
+	if err != nil {
+		t.state = StateFailed
+		t.err = err
+	} else {
+		t.state = StateCompleted
+	}
+	t.broadcast()
+	t.mu.Unlock()
+
+	if err == nil {
+		t.emit(Event{Type: EventCompleted})
+	}
+}
+
+func (t *Torrent) download(ctx context.Context) error {
+	t.mu.Lock()
+	addrs := slices.Clone(t.directPeers)
+	t.mu.Unlock()
+	var interval int
+	resp, err := t.announce(ctx, tracker.EventStarted)
+	if err != nil {
+		if len(addrs) == 0 {
+			return err
+		}
+	} else {
+		interval = resp.Interval
+		for _, addr := range resp.Peers {
+			if !slices.Contains(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	wq := newWorkQueue()
+	pool := newWorkerPool(wq)
+	// a listening client keeps waiting for peers to connect to it
+	pool.waitForWorkers = t.client.listener != nil
+	if t.Info() == nil {
+		addr, conn, err := t.fetchMetadata(ctx, addrs)
+		if err != nil {
+			return err
+		}
+		if !t.addPeer(ctx, pool, addr, conn, false) {
+			conn.Close()
+			t.client.releaseSlot()
+		}
+	}
+	if err := t.openStorage(); err != nil {
+		return err
+	}
+
+	incoming := make(chan incomingPeer)
+	t.mu.Lock()
+	priorities := make([]int, len(t.verified))
+	for i, ok := range t.verified {
+		priorities[i] = int(t.piecePriority(i))
+		if !ok && t.piecePriority(i) != PrioritySkip {
+			wq.addItem(i)
+		}
+	}
+	wq.prioritize(func(i int) int { return priorities[i] })
+	t.queue = wq
+	t.incoming = incoming
+	t.mu.Unlock()
+
+	t.connectPeers(ctx, pool, addrs)
+
+	peersCtx, stopPeers := context.WithCancel(ctx)
+	if t.trackerURL != "" {
+		go t.reannounce(peersCtx, pool, interval)
+	}
+	go t.acceptIncoming(peersCtx, pool, incoming)
+	err = pool.start(ctx)
+	stopPeers()
+	t.mu.Lock()
+	t.queue = nil
+	t.incoming = nil
+	out := t.out
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := out.Commit(); err != nil {
+		return err
+	}
+
+	t.announce(ctx, tracker.EventCompleted)
+	return nil
+}
+
+func (t *Torrent) announce(ctx context.Context, event string) (*tracker.AnnounceResponse, error) {
+	if t.trackerURL == "" {
+		return nil, fmt.Errorf("torrent has no tracker")
+	}
+
+	t.mu.Lock()
+	left := int64(-1)
+	if t.info != nil {
+		left = t.info.FileLength
+		for i, ok := range t.verified {
+			if ok {
+				left -= int64(t.info.PieceSize(i))
+			}
+		}
+	}
+	downloaded := t.downloaded
+	t.mu.Unlock()
+
+	announceCtx := ctx
+	if timeout := t.client.config.TrackerTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		announceCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp, err := tracker.Announce(announceCtx, tracker.AnnounceRequest{
+		TrackerURL: t.trackerURL,
+		InfoHash:   t.infoHash,
+		PeerID:     t.client.config.PeerID,
+		Port:       t.client.config.Port,
+		Downloaded: downloaded,
+		Left:       left,
+		Event:      event,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			t.emit(Event{Type: EventTrackerError, Err: err})
+		}
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.announced = true
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// reannounce announces to the tracker at the interval it asked for and
+// connects to peers that joined the swarm since, until ctx is done.
+func (t *Torrent) reannounce(ctx context.Context, pool *workerPool, intervalSeconds int) {
+	for {
+		interval := time.Duration(intervalSeconds) * time.Second
+		if interval == 0 {
+			interval = defaultAnnounceInterval
+		}
+		if interval < minAnnounceInterval {
+			interval = minAnnounceInterval
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		resp, err := t.announce(ctx, tracker.EventNone)
+		if err != nil {
+			continue
+		}
+		intervalSeconds = resp.Interval
+		t.connectPeers(ctx, pool, resp.Peers)
+	}
+}
+
+// offer hands an incoming connection to the torrent. It returns false if
+// the torrent is not downloading.
+func (t *Torrent) offer(addr string, conn *peerwire.Conn) bool {
+	t.mu.Lock()
+	incoming := t.incoming
+	t.mu.Unlock()
+	if incoming == nil {
+		return false
+	}
+	select {
+	case incoming <- incomingPeer{addr: addr, conn: conn}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acceptIncoming starts downloading from peers that connected to us until
+// ctx is done.
+func (t *Torrent) acceptIncoming(ctx context.Context, pool *workerPool, incoming chan incomingPeer) {
+	for {
+		select {
+		case p := <-incoming:
+			go func() {
+				if !t.wantPeer(p.addr) || prepareConn(p.conn) != nil || !t.addPeer(ctx, pool, p.addr, p.conn, true) {
+					p.conn.Close()
+					t.client.releaseSlot()
+				}
+			}()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fetchMetadata downloads the info dictionary from the first peer that
+// provides one matching the info hash, and returns the connection to that
+// peer ready to serve pieces.
+func (t *Torrent) fetchMetadata(ctx context.Context, addrs []string) (string, *peerwire.Conn, error) {
+	err := fmt.Errorf("no peers to fetch metadata from")
+	for _, addr := range addrs {
+		if !t.client.acquireSlot() {
+			return "", nil, fmt.Errorf("no free connection slots")
+		}
+		var conn *peerwire.Conn
+		conn, err = ConnectMagnet(ctx, addr, t.client.config.PeerID, t.infoHash)
+		if err != nil {
+			t.client.releaseSlot()
+			if ctx.Err() != nil {
+				return "", nil, ctx.Err()
+			}
+			continue
+		}
+
+		var info *metainfo.TorrentInfo
+		info, err = FetchMetadata(ctx, conn)
+		if err == nil && !bytes.Equal(info.InfoHash, t.infoHash) {
+			err = fmt.Errorf("peer %s sent metadata for another torrent", addr)
+		}
+		if err == nil {
+			err = conn.SendInterested()
+		}
+		if err != nil {
+			conn.Close()
+			t.client.releaseSlot()
+			if ctx.Err() != nil {
+				return "", nil, ctx.Err()
+			}
+			continue
+		}
+
+		t.mu.Lock()
+		t.info = info
+		t.mu.Unlock()
+		t.emit(Event{Type: EventMetadataReceived})
+		return addr, conn, nil
+	}
+	return "", nil, err
+}
+
+func (t *Torrent) openStorage() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.out != nil {
+		return nil
+	}
+
+	path := t.path()
+	entries := []storage.Entry{{Path: path, Length: t.info.FileLength}}
+	if t.info.MultiFile {
+		entries = entries[:0]
+		for _, f := range t.info.Files {
+			entries = append(entries, storage.Entry{
+				Path:   filepath.Join(path, filepath.Join(f.Path...)),
+				Length: f.Length,
+			})
+		}
+	}
+	out := storage.NewSet(t.info.PieceLength, entries)
+	for i := range entries {
+		out.Skip(i, t.filePriority(i) == PrioritySkip)
+	}
+	t.out = out
+	t.verified = make([]bool, t.info.NumPieces())
+	t.numVerified = 0
+	return nil
+}
+
+// connectPeers connects to the given peers in parallel, skipping peers we
+// are already connected to, and adds a worker to pool for each new
+// connection. Peers are skipped while the client has no free connection
+// slot.
+func (t *Torrent) connectPeers(ctx context.Context, pool *workerPool, addrs []string) {
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		if !t.wantPeer(addr) || !t.client.acquireSlot() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := Connect(ctx, addr, t.client.config.PeerID, t.infoHash)
+			if err != nil {
+				t.client.releaseSlot()
+				return
+			}
+			if !t.addPeer(ctx, pool, addr, conn, false) {
+				conn.Close()
+				t.client.releaseSlot()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (t *Torrent) wantPeer(addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.peers[addr]; ok {
+		return false
+	}
+	max := t.client.Limits().MaxPeers
+	return max == 0 || len(t.peers) < max
+}
+
+// addPeer registers a connection that is ready to serve pieces and starts
+// downloading from it. The connection holds one of the client's slots
+// until it is removed. It returns false if the peer is not wanted.
+func (t *Torrent) addPeer(ctx context.Context, pool *workerPool, addr string, conn *peerwire.Conn, incoming bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	limits := t.client.Limits()
+	t.mu.Lock()
+	if _, ok := t.peers[addr]; ok {
+		t.mu.Unlock()
+		return false
+	}
+	if max := limits.MaxPeers; max != 0 && len(t.peers) >= max {
+		t.mu.Unlock()
+		return false
+	}
+	p := &peer{
+		conn:          conn,
+		incoming:      incoming,
+		downloadLimit: ratelimit.New(limits.PeerDownloadRateLimit),
+		uploadLimit:   ratelimit.New(limits.PeerUploadRateLimit),
+	}
+	conn.SetUploadLimits(t.client.uploadLimit, t.uploadLimit, p.uploadLimit)
+	conn.SetPieceSent(func(n int) {
+		t.uploadRate.add(n)
+		p.uploadRate.add(n)
+		t.mu.Lock()
+		t.uploaded += int64(n)
+		p.uploaded += int64(n)
+		t.mu.Unlock()
+	})
+	t.peers[addr] = p
+	t.mu.Unlock()
+
+	t.emit(Event{Type: EventPeerConnected, Peer: addr})
+	pool.addWorker(ctx, t.newWorker(addr, p))
+	return true
+}
+
+func (t *Torrent) removePeer(addr string) {
+	t.mu.Lock()
+	p, ok := t.peers[addr]
+	delete(t.peers, addr)
+	t.mu.Unlock()
+	if ok {
+		p.conn.Close()
+		t.client.releaseSlot()
+	}
+}
+
+func (t *Torrent) closePeers() {
+	t.mu.Lock()
+	peers := t.peers
+	t.peers = make(map[string]*peer)
+	t.mu.Unlock()
+	for _, p := range peers {
+		p.conn.Close()
+		t.client.releaseSlot()
+	}
+}
+
+func (t *Torrent) newWorker(addr string, p *peer) *worker {
+	limits := []*ratelimit.Limiter{t.client.downloadLimit, t.downloadLimit, p.downloadLimit}
+	received := func(n int) {
+		t.rate.add(n)
+		p.rate.add(n)
+		t.mu.Lock()
+		t.downloaded += int64(n)
+		p.downloaded += int64(n)
+		t.mu.Unlock()
+	}
+	return &worker{
+		run: func(ctx context.Context, pieceIdx int) error {
+			info := t.Info()
+			pieceValue, err := downloadPiece(ctx, p.conn, info, pieceIdx, t.client.config.BlockSize, limits, received)
+			if err != nil {
+				return err
+			}
+
+			t.mu.Lock()
+			out := t.out
+			t.mu.Unlock()
+
+			if err := info.VerifyPiece(pieceIdx, pieceValue); err != nil {
+				return err
+			}
+			if err := out.WritePiece(pieceIdx, pieceValue); err != nil {
+				return err
+			}
+
+			t.mu.Lock()
+			if !t.verified[pieceIdx] {
+				t.verified[pieceIdx] = true
+				t.numVerified++
+			}
+			t.mu.Unlock()
+			t.emit(Event{Type: EventPieceVerified, Piece: pieceIdx})
+			return nil
+		},
+		stop: func() {
+			t.removePeer(addr)
+		},
+	}
+}
@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// maxWorkerFailures is the number of consecutive failed items after which a
+// worker is considered broken and leaves the pool.
+const maxWorkerFailures = 3
+
+type itemState int
+
+const (
+	itemQueued itemState = iota
+	itemInFlight
+	itemDone
+)
+
+// workqueue hands out items to workers until every item has been completed.
+// An empty queue is not a finished queue: while items are still in flight a
+// worker asking for more work blocks, because a failed item may be re-queued.
+type workqueue struct {
+	mu      sync.Mutex
+	pending []int
+	items   map[int]itemState
+	left    int
+	closed  bool
+	changed chan struct{}
+}
+
+func newWorkQueue() *workqueue {
+	return &workqueue{
+		items:   make(map[int]itemState),
+		changed: make(chan struct{}),
+	}
+}
+
+// broadcast wakes up every goroutine blocked in next or wait. It must be
+// called with w.mu held.
+func (w *workqueue) broadcast() {
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// addItem queues an item. Items that are already queued, in flight or done
+// are ignored, so it is safe to add the same item more than once.
+func (w *workqueue) addItem(item int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if _, ok := w.items[item]; ok {
+		return
+	}
+	w.items[item] = itemQueued
+	w.left++
+	w.pending = append(w.pending, item)
+	w.broadcast()
+}
+
+// retry puts an in-flight or completed item back on the queue.
+func (w *workqueue) retry(item int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	state, ok := w.items[item]
+	if ok && state == itemQueued {
+		return
+	}
+	if !ok || state == itemDone {
+		w.left++
+	}
+	w.items[item] = itemQueued
+	w.pending = append(w.pending, item)
+	w.broadcast()
+}
+
+// requeue puts a completed item back on the queue and adds an unknown
+// one. Unlike retry it leaves queued and in-flight items alone, so it is
+// safe to call for an item that may already be downloading.
+func (w *workqueue) requeue(item int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if state, ok := w.items[item]; ok && state != itemDone {
+		return
+	}
+	w.items[item] = itemQueued
+	w.left++
+	w.pending = append(w.pending, item)
+	w.broadcast()
+}
+
+// complete marks an item as done.
+func (w *workqueue) complete(item int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if state, ok := w.items[item]; !ok || state == itemDone {
+		return
+	}
+	w.items[item] = itemDone
+	w.left--
+	w.broadcast()
+}
+
+// remove takes a queued item off the queue, as if it had never been added.
+// Items that are in flight or done are left alone.
+func (w *workqueue) remove(item int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if state, ok := w.items[item]; !ok || state != itemQueued {
+		return
+	}
+	delete(w.items, item)
+	w.pending = slices.DeleteFunc(w.pending, func(i int) bool { return i == item })
+	w.left--
+	w.broadcast()
+}
+
+// prioritize reorders the queued items so that items with a higher
+// priority are handed out first. Items of equal priority keep their order.
+func (w *workqueue) prioritize(priority func(int) int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slices.SortStableFunc(w.pending, func(a, b int) int {
+		return priority(b) - priority(a)
+	})
+}
+
+// next blocks until an item is available and returns it. The boolean is
+// false once every item is done, the queue has been closed or ctx is done.
+func (w *workqueue) next(ctx context.Context) (int, bool) {
+	for {
+		w.mu.Lock()
+		if w.closed || w.left == 0 {
+			w.mu.Unlock()
+			return -1, false
+		}
+		if len(w.pending) > 0 {
+			item := w.pending[0]
+			w.pending = w.pending[1:]
+			w.items[item] = itemInFlight
+			w.mu.Unlock()
+			return item, true
+		}
+		changed := w.changed
+		w.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return -1, false
+		}
+	}
+}
+
+// wait blocks until every item is done or the queue has been closed. It
+// returns ctx.Err() if ctx is done first.
+func (w *workqueue) wait(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		if w.closed || w.left == 0 {
+			w.mu.Unlock()
+			return nil
+		}
+		changed := w.changed
+		w.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// close cancels the queue, releasing every blocked worker.
+func (w *workqueue) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.broadcast()
+}
+
+// remaining returns the number of items that are not done yet.
+func (w *workqueue) remaining() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.left
+}
+
+type worker struct {
+	run func(context.Context, int) error
+	// stop, if set, is called when the worker leaves the pool.
+	stop func()
+}
+
+type workerPool struct {
+	// waitForWorkers keeps the pool running while it has no workers, for
+	// callers that add workers as peers show up. Otherwise the pool gives
+	// up once its last worker left.
+	waitForWorkers bool
+
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	active    int
+	running   bool
+	finished  bool
+	lastErr   error
+	workqueue *workqueue
+	workers   []*worker
+}
+
+func newWorkerPool(work *workqueue, workers ...*worker) *workerPool {
+	return &workerPool{
+		workers:   workers,
+		workqueue: work,
+	}
+}
+
+// addWorker adds a worker to the pool. Workers added while the pool is
+// running start picking up items immediately.
+func (w *workerPool) addWorker(ctx context.Context, wk *worker) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.workers = append(w.workers, wk)
+	if w.running && !w.finished && (w.active > 0 || w.waitForWorkers) {
+		w.active++
+		w.wg.Add(1)
+		go w.runWorker(ctx, wk)
+	}
+}
+
+// start runs the workers until every item in the queue is done. It returns
+// an error when all workers gave up before the queue was finished, or
+// ctx.Err() when ctx is done first.
+func (w *workerPool) start(ctx context.Context) error {
+	w.mu.Lock()
+	w.running = true
+	if len(w.workers) == 0 && !w.waitForWorkers {
+		w.workqueue.close()
+	}
+	for _, wk := range w.workers {
+		w.active++
+		w.wg.Add(1)
+		go w.runWorker(ctx, wk)
+	}
+	w.mu.Unlock()
+
+	err := w.workqueue.wait(ctx)
+	w.mu.Lock()
+	w.finished = true
+	w.mu.Unlock()
+	w.workqueue.close()
+	w.wg.Wait()
+
+	if err != nil {
+		return err
+	}
+	if n := w.workqueue.remaining(); n != 0 {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return fmt.Errorf("%d items left unfinished, no workers left: %w", n, w.lastErr)
+	}
+	return nil
+}
+
+func (w *workerPool) runWorker(ctx context.Context, wk *worker) {
+	defer w.wg.Done()
+	if wk.stop != nil {
+		defer wk.stop()
+	}
+	defer func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.active--
+		if w.active == 0 && !w.waitForWorkers {
+			w.workqueue.close()
+		}
+	}()
+
+	failures := 0
+	for {
+		item, ok := w.workqueue.next(ctx)
+		if !ok {
+			return
+		}
+
+		if err := wk.run(ctx, item); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.workqueue.retry(item)
+			failures++
+			if failures >= maxWorkerFailures {
+				w.mu.Lock()
+				w.lastErr = err
+				w.mu.Unlock()
+				return
+			}
+			continue
+		}
+		failures = 0
+		w.workqueue.complete(item)
+	}
+}
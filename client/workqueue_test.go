@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkqueue(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// run changes a queue holding items 0, 1 and 2 after 0 was handed
+		// out.
+		run       func(q *workqueue)
+		remaining int
+		pending   []int
+	}{
+		{"complete", func(q *workqueue) { q.complete(0) }, 2, []int{1, 2}},
+		{"complete twice", func(q *workqueue) { q.complete(0); q.complete(0) }, 2, []int{1, 2}},
+		{"complete unknown", func(q *workqueue) { q.complete(7) }, 3, []int{1, 2}},
+		{"retry in flight", func(q *workqueue) { q.retry(0) }, 3, []int{1, 2, 0}},
+		{"retry done", func(q *workqueue) { q.complete(0); q.retry(0) }, 3, []int{1, 2, 0}},
+		{"retry queued", func(q *workqueue) { q.retry(1) }, 3, []int{1, 2}},
+		{"remove queued", func(q *workqueue) { q.remove(1) }, 2, []int{2}},
+		{"remove in flight", func(q *workqueue) { q.remove(0) }, 3, []int{1, 2}},
+		{"remove then add", func(q *workqueue) { q.remove(1); q.addItem(1) }, 3, []int{2, 1}},
+		{"add existing", func(q *workqueue) { q.complete(0); q.addItem(0); q.addItem(1) }, 2, []int{1, 2}},
+		{"requeue done", func(q *workqueue) { q.complete(0); q.requeue(0) }, 3, []int{1, 2, 0}},
+		{"requeue in flight", func(q *workqueue) { q.requeue(0) }, 3, []int{1, 2}},
+		{"requeue unknown", func(q *workqueue) { q.requeue(5) }, 4, []int{1, 2, 5}},
+		{"prioritize", func(q *workqueue) { q.prioritize(func(i int) int { return i }) }, 3, []int{2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newWorkQueue()
+			for i := range 3 {
+				q.addItem(i)
+			}
+			if item, ok := q.next(ctx); !ok || item != 0 {
+				t.Fatalf("next() = %d, %v, want 0, true", item, ok)
+			}
+			tt.run(q)
+			if got := q.remaining(); got != tt.remaining {
+				t.Errorf("remaining() = %d, want %d", got, tt.remaining)
+			}
+			for _, want := range tt.pending {
+				if item, ok := q.next(ctx); !ok || item != want {
+					t.Errorf("next() = %d, %v, want %d, true", item, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkqueueNextBlocksWhileInFlight(t *testing.T) {
+	q := newWorkQueue()
+	q.addItem(0)
+	item, _ := q.next(context.Background())
+
+	got := make(chan int)
+	go func() {
+		item, _ := q.next(context.Background())
+		got <- item
+	}()
+	select {
+	case item := <-got:
+		t.Fatalf("next() returned %d while the only item was in flight", item)
+	case <-time.After(10 * time.Millisecond):
+	}
+	q.retry(item)
+	if item := <-got; item != 0 {
+		t.Errorf("next() after retry = %d, want 0", item)
+	}
+
+	q.complete(0)
+	if item, ok := q.next(context.Background()); ok {
+		t.Errorf("next() on a finished queue = %d, true", item)
+	}
+	if err := q.wait(context.Background()); err != nil {
+		t.Errorf("wait() on a finished queue = %v", err)
+	}
+}
+
+func TestWorkqueueClose(t *testing.T) {
+	q := newWorkQueue()
+	q.addItem(0)
+	q.close()
+	if _, ok := q.next(context.Background()); ok {
+		t.Error("next() on a closed queue returned an item")
+	}
+	q.addItem(1)
+	q.retry(0)
+	if got := q.remaining(); got != 1 {
+		t.Errorf("remaining() after close = %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q = newWorkQueue()
+	q.addItem(0)
+	if err := q.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() with a cancelled context = %v", err)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	q := newWorkQueue()
+	for i := range 10 {
+		q.addItem(i)
+	}
+	fails := map[int]bool{3: true, 7: true}
+	done := make(chan int, 20)
+	flaky := &worker{run: func(ctx context.Context, item int) error {
+		if fails[item] {
+			delete(fails, item)
+			return errors.New("failed")
+		}
+		done <- item
+		return nil
+	}}
+	// flaky is the only worker, so failed items must be retried by it
+	if err := newWorkerPool(q, flaky).start(context.Background()); err != nil {
+		t.Fatalf("start() error: %v", err)
+	}
+	close(done)
+	seen := make(map[int]bool)
+	for item := range done {
+		seen[item] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("items done = %v, want all 10", seen)
+	}
+
+	q = newWorkQueue()
+	q.addItem(0)
+	broken := &worker{run: func(ctx context.Context, item int) error { return errors.New("broken") }}
+	if err := newWorkerPool(q, broken).start(context.Background()); err == nil {
+		t.Error("start() with a broken worker succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/tracker"
+)
+
+// stoppedAnnounceTimeout bounds the best-effort "stopped" announce sent on
+// shutdown, when the command's own context is already cancelled.
+const stoppedAnnounceTimeout = 5 * time.Second
+
+// fetchPeers returns the peers given with --peer, or if there are none the
+// peers the tracker returns, up to --peer-limit.
+func (o *networkOptions) fetchPeers(ctx context.Context, trackerUrl string, infoHash []byte, fileLength int64, clientId string) ([]string, error) {
+	if len(o.peers) > 0 {
+		return o.peers, nil
+	}
+	resp, err := o.announcePeers(ctx, trackerUrl, infoHash, fileLength, clientId)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Peers, nil
+}
+
+// announcePeers announces to the tracker and returns its reply, which is
+// an error if it holds no peers. At most --peer-limit peers are kept.
+func (o *networkOptions) announcePeers(ctx context.Context, trackerUrl string, infoHash []byte, fileLength int64, clientId string) (*tracker.AnnounceResponse, error) {
+	if cfg.TrackerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.TrackerTimeout)
+		defer cancel()
+	}
+	resp, err := tracker.Announce(ctx, tracker.AnnounceRequest{
+		TrackerURL: trackerUrl,
+		InfoHash:   infoHash,
+		PeerID:     clientId,
+		Port:       o.port,
+		Left:       fileLength,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Peers) == 0 {
+		return nil, fmt.Errorf("tracker returned no peers")
+	}
+	if o.peerLimit > 0 && len(resp.Peers) > o.peerLimit {
+		resp.Peers = resp.Peers[:o.peerLimit]
+	}
+	return resp, nil
+}
+
+// announceStoppedOnCancel tells the tracker that we are leaving the swarm if
+// ctx was cancelled. It is meant to be deferred by commands that announced
+// themselves to a tracker. Nothing is sent if fetchPeers did not ask the
+// tracker.
+func (o *networkOptions) announceStoppedOnCancel(ctx context.Context, trackerUrl string, infoHash []byte, fileLength int64, clientId string) {
+	if ctx.Err() == nil || len(o.peers) > 0 {
+		return
+	}
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stoppedAnnounceTimeout)
+	defer cancel()
+	_, err := tracker.Announce(stopCtx, tracker.AnnounceRequest{
+		TrackerURL: trackerUrl,
+		InfoHash:   infoHash,
+		PeerID:     clientId,
+		Port:       o.port,
+		Left:       fileLength,
+		Event:      tracker.EventStopped,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "stopped announce:", err)
+	}
+}
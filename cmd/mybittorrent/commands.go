@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+	"github.com/codecrafters-io/bittorrent-starter-go/magnet"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/peerwire"
+	"github.com/codecrafters-io/bittorrent-starter-go/storage"
+)
+
+func runDecode(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	strict := strictFlag(flags)
+	lossless := flags.Bool("lossless", false, "print byte strings that are not valid UTF-8 as {\"$base64\": ...} objects, so that encode gives back the same bencode")
+	pos, err := parseArgsRange(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	input, err := argOrStdin(pos)
+	if err != nil {
+		return err
+	}
+	if *strict {
+		if err := bencode.Check(input); err != nil {
+			return err
+		}
+	}
+	// bencode integers have no size limit
+	d := bencode.NewDecoder(bytes.NewReader(input))
+	d.UseBigInt()
+	decoded, err := d.Decode()
+	if err != nil {
+		return err
+	}
+	if *lossless {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(bencode.ToJSON(decoded))
+	}
+	jsonOutput, err := json.Marshal(stringsToJSON(decoded))
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
+func runInfo(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	jsonOutput := jsonFlag(flags)
+	strict := strictFlag(flags)
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *strict {
+		data, err := os.ReadFile(pos[0])
+		if err != nil {
+			return err
+		}
+		if err := bencode.Check(data); err != nil {
+			return fmt.Errorf("%s: %v", pos[0], err)
+		}
+	}
+	torrentInfo, err := metainfo.FromFile(pos[0])
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		printJSON(torrentInfoJSON(torrentInfo))
+		return nil
+	}
+	printTorrentInfo(torrentInfo)
+	return nil
+}
+
+func runMagnet(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	torrentInfo, err := metainfo.FromFile(pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(magnet.FromTorrent(torrentInfo))
+	return nil
+}
+
+func runPeers(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.register(flags)
+	jsonOutput := jsonFlag(flags)
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	torrentInfo, err := metainfo.FromFile(pos[0])
+	if err != nil {
+		return err
+	}
+	resp, err := net.announcePeers(ctx, torrentInfo.TrackerURL, torrentInfo.InfoHash, torrentInfo.FileLength, cfg.peerID())
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		printJSON(trackerPeersJSON(resp))
+		return nil
+	}
+	for _, v := range resp.Peers {
+		fmt.Println(v)
+	}
+	return nil
+}
+
+func runHandshake(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.registerTimeout(flags)
+	jsonOutput := jsonFlag(flags)
+	pos, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	torrentInfo, err := metainfo.FromFile(pos[0])
+	if err != nil {
+		return err
+	}
+	conn, err := peerwire.Dial(ctx, pos[1], cfg.peerID(), torrentInfo.InfoHash, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if *jsonOutput {
+		printJSON(peerJSON{Addr: pos[1], PeerID: optional(hex.EncodeToString(conn.PeerID))})
+		return nil
+	}
+	fmt.Printf("Peer ID: %x\n", conn.PeerID)
+	return nil
+}
+
+func runDownloadPiece(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.register(flags)
+	net.registerPeers(flags)
+	output := outputFlag(flags, "file to write the piece to")
+	pos, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	if err := requireOutput(flags, *output); err != nil {
+		return err
+	}
+	index, err := parsePieceIndex(pos[1])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	torrentInfo, err := metainfo.FromFile(pos[0])
+	if err != nil {
+		return err
+	}
+	clientId := cfg.peerID()
+	peerUrls, err := net.fetchPeers(ctx, torrentInfo.TrackerURL, torrentInfo.InfoHash, torrentInfo.FileLength, clientId)
+	if err != nil {
+		return err
+	}
+	defer net.announceStoppedOnCancel(ctx, torrentInfo.TrackerURL, torrentInfo.InfoHash, torrentInfo.FileLength, clientId)
+	conn, err := client.Connect(ctx, peerUrls[0], clientId, torrentInfo.InfoHash)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return downloadPieceTo(ctx, conn, torrentInfo, index, *output)
+}
+
+func runDownload(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.register(flags)
+	net.registerPeers(flags)
+	output := outputFlag(flags, "path to save the torrent at, by default its name in the configured download-dir")
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if cfg.DownloadDir == "" {
+		if err := requireOutput(flags, *output); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	c, err := net.newClient()
+	if err != nil {
+		return err
+	}
+	t, err := c.AddTorrentFile(pos[0])
+	if err != nil {
+		c.Close()
+		return err
+	}
+	t.AddPeers(net.peers...)
+	if *output != "" {
+		t.SetOutputPath(*output)
+	}
+	return runTorrent(ctx, c, t)
+}
+
+func runMagnetParse(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	jsonOutput := jsonFlag(flags)
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	mag, err := magnet.Parse(pos[0])
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		printJSON(magnetLinkJSON(mag))
+		return nil
+	}
+	fmt.Println("Tracker URL:", mag.Tracker())
+	fmt.Printf("Info Hash: %x\n", mag.InfoHash)
+	return nil
+}
+
+func runMagnetHandshake(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.register(flags)
+	net.registerPeers(flags)
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	mag, err := magnet.Parse(pos[0])
+	if err != nil {
+		return err
+	}
+	infoHash := mag.InfoHash
+	net.peers = append(net.peers, mag.Peers...)
+	clientId := cfg.peerID()
+	peerUrls, err := net.fetchPeers(ctx, mag.Tracker(), infoHash, -1, clientId)
+	if err != nil {
+		return err
+	}
+	conn, err := client.ConnectMagnet(ctx, peerUrls[0], clientId, infoHash)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Printf("Peer ID: %x\n", conn.PeerID)
+	handshake, err := conn.ExtensionHandshake()
+	if err != nil {
+		return err
+	}
+	metadataExtId, err := peerwire.PeerMetadataExtensionID(handshake)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Peer Metadata Extension ID: %d\n", metadataExtId)
+	return nil
+}
+
+func runMagnetInfo(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.register(flags)
+	net.registerPeers(flags)
+	jsonOutput := jsonFlag(flags)
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	mag, err := magnet.Parse(pos[0])
+	if err != nil {
+		return err
+	}
+	infoHash := mag.InfoHash
+	net.peers = append(net.peers, mag.Peers...)
+	clientId := cfg.peerID()
+	peerUrls, err := net.fetchPeers(ctx, mag.Tracker(), infoHash, -1, clientId)
+	if err != nil {
+		return err
+	}
+	conn, err := client.ConnectMagnet(ctx, peerUrls[0], clientId, infoHash)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	torrentInfo, err := client.FetchMetadata(ctx, conn)
+	if err != nil {
+		return err
+	}
+	torrentInfo.TrackerURL = mag.Tracker()
+	if *jsonOutput {
+		printJSON(torrentInfoJSON(torrentInfo))
+		return nil
+	}
+	printTorrentInfo(torrentInfo)
+	return nil
+}
+
+func runMagnetDownloadPiece(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.register(flags)
+	net.registerPeers(flags)
+	output := outputFlag(flags, "file to write the piece to")
+	pos, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	if err := requireOutput(flags, *output); err != nil {
+		return err
+	}
+	index, err := parsePieceIndex(pos[1])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	mag, err := magnet.Parse(pos[0])
+	if err != nil {
+		return err
+	}
+	infoHash := mag.InfoHash
+	net.peers = append(net.peers, mag.Peers...)
+	clientId := cfg.peerID()
+	peerUrls, err := net.fetchPeers(ctx, mag.Tracker(), infoHash, -1, clientId)
+	if err != nil {
+		return err
+	}
+	defer net.announceStoppedOnCancel(ctx, mag.Tracker(), infoHash, -1, clientId)
+	conn, err := client.ConnectMagnet(ctx, peerUrls[0], clientId, infoHash)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	torrentInfo, err := client.FetchMetadata(ctx, conn)
+	if err != nil {
+		return err
+	}
+	torrentInfo.TrackerURL = mag.Tracker()
+	if err := conn.SendInterested(); err != nil {
+		return err
+	}
+	return downloadPieceTo(ctx, conn, torrentInfo, index, *output)
+}
+
+func runMagnetDownload(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	var net networkOptions
+	net.register(flags)
+	net.registerPeers(flags)
+	output := outputFlag(flags, "path to save the torrent at, by default its name in the configured download-dir")
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if cfg.DownloadDir == "" {
+		if err := requireOutput(flags, *output); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := net.context(ctx)
+	defer cancel()
+
+	c, err := net.newClient()
+	if err != nil {
+		return err
+	}
+	t, err := c.AddMagnet(pos[0])
+	if err != nil {
+		c.Close()
+		return err
+	}
+	t.AddPeers(net.peers...)
+	if *output != "" {
+		t.SetOutputPath(*output)
+	}
+	return runTorrent(ctx, c, t)
+}
+
+func jsonFlag(flags *flag.FlagSet) *bool {
+	return flags.Bool("json", false, "print the result as JSON")
+}
+
+func strictFlag(flags *flag.FlagSet) *bool {
+	return flags.Bool("strict", false, "reject bencode that is not in canonical form, reporting the offset of the first problem")
+}
+
+// newClient returns a client for a download command.
+func (o *networkOptions) newClient() (*client.Client, error) {
+	config := cfg.clientConfig()
+	config.Port = o.port
+	config.Limits.MaxPeers = o.peerLimit
+	return client.NewClient(config)
+}
+
+func parsePieceIndex(s string) (int, error) {
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 {
+		return 0, &usageError{fmt.Sprintf("invalid piece index %q", s)}
+	}
+	return index, nil
+}
+
+// downloadPieceTo downloads piece index from conn, verifies it and writes it
+// to the named file.
+func downloadPieceTo(ctx context.Context, conn *peerwire.Conn, torrentInfo *metainfo.TorrentInfo, index int, name string) error {
+	if index >= torrentInfo.NumPieces() {
+		return fmt.Errorf("piece index %d out of range, the torrent has %d pieces", index, torrentInfo.NumPieces())
+	}
+	fileData, err := client.DownloadPieceBlocks(ctx, conn, torrentInfo, index, cfg.BlockSize)
+	if err != nil {
+		return err
+	}
+	if err := torrentInfo.VerifyPiece(index, fileData); err != nil {
+		return err
+	}
+	return storage.WriteFile(name, fileData)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+	"github.com/codecrafters-io/bittorrent-starter-go/magnet"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+// runCommand runs the command name with args and returns what it printed
+// to stdout.
+func runCommand(t *testing.T, name string, args ...string) string {
+	t.Helper()
+	cmd := findCommand(name)
+	if cmd == nil {
+		t.Fatalf("no command %q", name)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	err = cmd.run(context.Background(), cmd, args)
+	os.Stdout = stdout
+	w.Close()
+	printed := <-out
+	if err != nil {
+		t.Fatalf("%s %s: %v", name, strings.Join(args, " "), err)
+	}
+	return printed
+}
+
+// writeFile writes data to a file in a temporary directory and returns its
+// path.
+func writeFile(t *testing.T, name string, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInfoJSONWithoutNameOrAnnounce(t *testing.T) {
+	path := writeFile(t, "a.torrent", "d4:infod6:lengthi3e12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaaee")
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(runCommand(t, "info", "--json", path)), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"name", "announce", "creation_date"} {
+		if v, ok := got[key]; !ok || v != nil {
+			t.Errorf("%s = %v, want null", key, v)
+		}
+	}
+	if got["length"] != 3.0 {
+		t.Errorf("length = %v, want 3", got["length"])
+	}
+}
+
+func TestHandshakeFlags(t *testing.T) {
+	cmd := findCommand("handshake")
+	// handshake never announces, so the options that only matter to
+	// trackers are rejected rather than ignored.
+	for _, flag := range []string{"--port=1", "--peer-limit=1"} {
+		err := cmd.run(context.Background(), cmd, []string{flag, "a.torrent", "127.0.0.1:1"})
+		var usage *usageError
+		if !errors.As(err, &usage) {
+			t.Errorf("handshake %s error = %v, want a usage error", flag, err)
+		}
+	}
+}
+
+func TestEditKeepsInfoBytes(t *testing.T) {
+	// the info dictionary is not canonical: its keys are not sorted
+	info := "d4:name1:x6:lengthi3e12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaae"
+	path := writeFile(t, "a.torrent", "d8:announce3:old4:info"+info+"e")
+	out := filepath.Join(filepath.Dir(path), "b.torrent")
+	runCommand(t, "edit", "--clear-announce", "--announce", "http://a/announce", "--comment", "new", "-o", out, path)
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := bencode.RawDict(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["info"]) != info {
+		t.Errorf("info dictionary = %q, want %q", fields["info"], info)
+	}
+	if string(fields["announce"]) != "17:http://a/announce" || string(fields["comment"]) != "3:new" {
+		t.Errorf("announce %q, comment %q", fields["announce"], fields["comment"])
+	}
+}
+
+func TestMagnetRoundTrip(t *testing.T) {
+	path := writeFile(t, "a.torrent", "d8:announce21:http://x/announce?a=b4:infod6:lengthi3e4:name7:a b+c&d12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaaee")
+	torrentInfo, err := metainfo.FromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := strings.TrimSpace(runCommand(t, "magnet", path))
+	link, err := magnet.Parse(out)
+	if err != nil {
+		t.Fatalf("magnet.Parse(%q) error: %v", out, err)
+	}
+	if !bytes.Equal(link.InfoHash, torrentInfo.InfoHash) || link.Name != "a b+c&d" || link.Tracker() != "http://x/announce?a=b" || link.Length != 3 {
+		t.Errorf("magnet.Parse(%q) = %+v", out, link)
+	}
+}
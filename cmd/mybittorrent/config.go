@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+)
+
+// configEnv names the environment variable that overrides the path of the
+// config file.
+const configEnv = "BITTORRENT_CONFIG"
+
+// envPrefix is the prefix of the environment variables overriding
+// settings. The variable for the setting "max-peers" is
+// BITTORRENT_MAX_PEERS.
+const envPrefix = "BITTORRENT_"
+
+// settings are the persistent settings of the CLI. They start from their
+// defaults, are overridden by the config file, then by environment
+// variables, and finally by the flags of a command, which default to them.
+type settings struct {
+	Port              int
+	PeerIDPrefix      string
+	DownloadDir       string
+	DownloadLimit     int
+	UploadLimit       int
+	PeerDownloadLimit int
+	PeerUploadLimit   int
+	MaxPeers          int
+	MaxConnections    int
+	TrackerTimeout    time.Duration
+	BlockSize         int
+}
+
+// cfg holds the settings loaded when the program starts.
+var cfg = defaultSettings()
+
+func defaultSettings() *settings {
+	return &settings{
+		Port:           client.DefaultPort,
+		TrackerTimeout: 30 * time.Second,
+		BlockSize:      client.BlockSize,
+	}
+}
+
+// flagSet returns a flag set whose flags are the settings, which is how the
+// config file and the environment variables are applied.
+func (s *settings) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("settings", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.IntVar(&s.Port, "port", s.Port, "")
+	flags.StringVar(&s.PeerIDPrefix, "peer-id-prefix", s.PeerIDPrefix, "")
+	flags.StringVar(&s.DownloadDir, "download-dir", s.DownloadDir, "")
+	flags.IntVar(&s.DownloadLimit, "download-limit", s.DownloadLimit, "")
+	flags.IntVar(&s.UploadLimit, "upload-limit", s.UploadLimit, "")
+	flags.IntVar(&s.PeerDownloadLimit, "peer-download-limit", s.PeerDownloadLimit, "")
+	flags.IntVar(&s.PeerUploadLimit, "peer-upload-limit", s.PeerUploadLimit, "")
+	flags.IntVar(&s.MaxPeers, "max-peers", s.MaxPeers, "")
+	flags.IntVar(&s.MaxConnections, "max-connections", s.MaxConnections, "")
+	flags.Var((*secondsValue)(&s.TrackerTimeout), "tracker-timeout", "")
+	flags.IntVar(&s.BlockSize, "block-size", s.BlockSize, "")
+	return flags
+}
+
+// secondsValue is a flag.Value for a duration written either like "30s"
+// or as a number of seconds, which is what a JSON config file can hold.
+type secondsValue time.Duration
+
+func (d *secondsValue) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *secondsValue) Set(s string) error {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		*d = secondsValue(secs * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("not a duration or a number of seconds")
+	}
+	*d = secondsValue(v)
+	return nil
+}
+
+// configPath returns the path of the config file: $BITTORRENT_CONFIG, or
+// mybittorrent/config.json in the user's config directory, which is
+// $XDG_CONFIG_HOME or ~/.config on Linux.
+func configPath() (string, error) {
+	if path := os.Getenv(configEnv); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mybittorrent", "config.json"), nil
+}
+
+// loadSettings returns the defaults overridden by the config file, if it
+// exists, and the environment.
+func loadSettings() (*settings, error) {
+	s := defaultSettings()
+	flags := s.flagSet()
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := applyConfigFile(flags, data); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	var envErr error
+	flags.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(name); ok && envErr == nil {
+			if err := flags.Set(f.Name, v); err != nil {
+				envErr = fmt.Errorf("%s: invalid value %q: %v", name, v, err)
+			}
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+	return s, s.validate()
+}
+
+// applyConfigFile sets the settings found in a config file, a JSON object
+// whose keys are the names of the settings.
+func applyConfigFile(flags *flag.FlagSet, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return err
+	}
+	for name, v := range values {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		switch v.(type) {
+		case string, json.Number, bool:
+		default:
+			return fmt.Errorf("setting %q is not a string or a number", name)
+		}
+		if err := flags.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("setting %q: invalid value %v: %v", name, v, err)
+		}
+	}
+	return nil
+}
+
+func (s *settings) validate() error {
+	if s.Port <= 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid port %d", s.Port)
+	}
+	if len(s.PeerIDPrefix) > 20 {
+		return fmt.Errorf("peer ID prefix %q is longer than 20 bytes", s.PeerIDPrefix)
+	}
+	if s.TrackerTimeout < 0 {
+		return fmt.Errorf("invalid tracker timeout %v", s.TrackerTimeout)
+	}
+	if s.BlockSize <= 0 || s.BlockSize > client.BlockSize {
+		return fmt.Errorf("invalid block size %d, must be 1 to %d bytes", s.BlockSize, client.BlockSize)
+	}
+	return nil
+}
+
+// peerID returns a new peer ID with the configured prefix.
+func (s *settings) peerID() string {
+	id, _ := client.GenPeerIDWithPrefix(s.PeerIDPrefix)
+	return id
+}
+
+// clientConfig returns the client configuration for the settings.
+func (s *settings) clientConfig() client.Config {
+	return client.Config{
+		PeerIDPrefix:   s.PeerIDPrefix,
+		Port:           s.Port,
+		DownloadDir:    s.DownloadDir,
+		BlockSize:      s.BlockSize,
+		TrackerTimeout: s.TrackerTimeout,
+		Limits: client.Limits{
+			MaxPeers:              s.MaxPeers,
+			MaxConnections:        s.MaxConnections,
+			DownloadRateLimit:     s.DownloadLimit,
+			UploadRateLimit:       s.UploadLimit,
+			PeerDownloadRateLimit: s.PeerDownloadLimit,
+			PeerUploadRateLimit:   s.PeerUploadLimit,
+		},
+	}
+}
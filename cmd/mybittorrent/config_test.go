@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+)
+
+func TestApplyConfigFile(t *testing.T) {
+	tests := []struct {
+		in      string
+		timeout time.Duration
+		port    int
+	}{
+		{`{"tracker-timeout": 45}`, 45 * time.Second, client.DefaultPort},
+		{`{"tracker-timeout": 1.5}`, 1500 * time.Millisecond, client.DefaultPort},
+		{`{"tracker-timeout": "1m30s"}`, 90 * time.Second, client.DefaultPort},
+		{`{"tracker-timeout": "10", "port": 7000}`, 10 * time.Second, 7000},
+	}
+	for _, tt := range tests {
+		s := defaultSettings()
+		if err := applyConfigFile(s.flagSet(), []byte(tt.in)); err != nil {
+			t.Errorf("applyConfigFile(%s) error: %v", tt.in, err)
+			continue
+		}
+		if s.TrackerTimeout != tt.timeout || s.Port != tt.port {
+			t.Errorf("applyConfigFile(%s) = timeout %v, port %d, want %v, %d", tt.in, s.TrackerTimeout, s.Port, tt.timeout, tt.port)
+		}
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"tracker-timeout": "soon"}`,
+		`{"tracker-timeout": [30]}`,
+		`{"dht-bootstrap-nodes": "router.example.com:6881"}`,
+		`{"port": "x"}`,
+		`[]`,
+	} {
+		s := defaultSettings()
+		if err := applyConfigFile(s.flagSet(), []byte(in)); err == nil {
+			t.Errorf("applyConfigFile(%s) succeeded", in)
+		}
+	}
+}
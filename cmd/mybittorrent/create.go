@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/storage"
+)
+
+// createdBy is the default "created by" of created torrents.
+const createdBy = "mybittorrent"
+
+// listFlag is a flag that may be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func runCreate(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	output := outputFlag(flags, "file to write the metainfo to, by default the name of the data with .torrent appended")
+	pieceLength := flags.Int("piece-length", 0, "piece length in bytes, a power of two; 0 to choose it from the total size")
+	var announce, webSeeds listFlag
+	flags.Var(&announce, "announce", "tracker URL; repeat for more tiers and separate the URLs of one tier with commas")
+	flags.Var(&webSeeds, "web-seed", "HTTP seed URL; may be repeated")
+	comment := flags.String("comment", "", "comment")
+	created := flags.String("created-by", createdBy, "program recorded as the creator")
+	date := flags.String("creation-date", "now", "creation date: \"now\", \"none\", seconds since the Unix epoch or RFC 3339")
+	private := flags.Bool("private", false, "restrict peers to those the tracker returns")
+	source := flags.String("source", "", "source tag, stored in the info dictionary")
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	creationDate, err := parseCreationDate(*date)
+	if err != nil {
+		return &usageError{err.Error()}
+	}
+
+	opts := metainfo.CreateOptions{
+		PieceLength:  *pieceLength,
+		WebSeeds:     webSeeds,
+		Comment:      *comment,
+		CreatedBy:    *created,
+		CreationDate: creationDate,
+		Private:      *private,
+		Source:       *source,
+		AnnounceList: announceTiers(announce),
+	}
+
+	data, err := metainfo.Create(pos[0], opts)
+	if err != nil {
+		return err
+	}
+	torrentInfo, err := metainfo.FromBytes(data)
+	if err != nil {
+		return err
+	}
+	path := *output
+	if path == "" {
+		path = torrentInfo.Name + ".torrent"
+	}
+	if err := storage.WriteFile(path, data); err != nil {
+		return err
+	}
+	fmt.Println("Created:", filepath.Clean(path))
+	fmt.Printf("Info Hash: %x\n", torrentInfo.InfoHash)
+	return nil
+}
+
+// announceTiers returns the tiers of tracker URLs given with --announce,
+// one tier per option with its URLs separated by commas.
+func announceTiers(announce listFlag) [][]string {
+	var tiers [][]string
+	for _, tier := range announce {
+		var urls []string
+		for _, url := range strings.Split(tier, ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				urls = append(urls, url)
+			}
+		}
+		tiers = append(tiers, urls)
+	}
+	return tiers
+}
+
+// parseCreationDate parses the --creation-date option. It returns the zero
+// time for "none".
+func parseCreationDate(s string) (time.Time, error) {
+	switch s {
+	case "now":
+		return time.Now(), nil
+	case "none":
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid creation date %q", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+	"github.com/codecrafters-io/bittorrent-starter-go/rpc"
+	"github.com/codecrafters-io/bittorrent-starter-go/watch"
+)
+
+// shutdownTimeout bounds how long the daemon waits for RPC requests in
+// progress when it stops.
+const shutdownTimeout = 10 * time.Second
+
+// runDaemon runs a client session controlled through the JSON-RPC API until
+// ctx is cancelled. args are the command line arguments after "daemon".
+func runDaemon(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	rpcAddr := flags.String("rpc", "127.0.0.1:9091", "address the JSON-RPC API listens on")
+	listenAddr := flags.String("listen", fmt.Sprintf(":%d", cfg.Port), "address to accept peer connections on")
+	maxPeers := flags.Int("max-peers", cfg.MaxPeers, "maximum number of peers per torrent, 0 for no limit")
+	maxConns := flags.Int("max-connections", cfg.MaxConnections, "maximum number of peer connections, 0 for no limit")
+	downloadLimit := flags.Int("download-limit", cfg.DownloadLimit, "download rate limit in bytes per second, 0 for no limit")
+	uploadLimit := flags.Int("upload-limit", cfg.UploadLimit, "upload rate limit in bytes per second, 0 for no limit")
+	peerDownloadLimit := flags.Int("peer-download-limit", cfg.PeerDownloadLimit, "download rate limit of every peer in bytes per second, 0 for no limit")
+	peerUploadLimit := flags.Int("peer-upload-limit", cfg.PeerUploadLimit, "upload rate limit of every peer in bytes per second, 0 for no limit")
+	altDownloadLimit := flags.Int("alt-download-limit", 0, "download rate limit while -alt-schedule is active, 0 for no limit")
+	altUploadLimit := flags.Int("alt-upload-limit", 0, "upload rate limit while -alt-schedule is active, 0 for no limit")
+	altSchedule := flags.String("alt-schedule", "", "time window for the alternative rate limits, such as \"mon-fri 09:00-18:00\"")
+	watchDir := flags.String("watch", "", "directory to pick up .torrent and .magnet files from")
+	watchOutput := flags.String("watch-output", "", "directory torrents from the watch directory are saved in, the download directory by default")
+	pos, err := parseArgsRange(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	downloadDir := cfg.DownloadDir
+	if len(pos) == 1 {
+		downloadDir = pos[0]
+	}
+	if downloadDir == "" {
+		flags.Usage()
+		return &usageError{"no download directory given or configured"}
+	}
+
+	limits := client.Limits{
+		MaxPeers:              *maxPeers,
+		MaxConnections:        *maxConns,
+		DownloadRateLimit:     *downloadLimit,
+		UploadRateLimit:       *uploadLimit,
+		PeerDownloadRateLimit: *peerDownloadLimit,
+		PeerUploadRateLimit:   *peerUploadLimit,
+		AltDownloadRateLimit:  *altDownloadLimit,
+		AltUploadRateLimit:    *altUploadLimit,
+	}
+	if *altSchedule != "" {
+		sched, err := ratelimit.ParseSchedule(*altSchedule)
+		if err != nil {
+			return err
+		}
+		limits.AltSchedule = sched
+	}
+
+	config := cfg.clientConfig()
+	config.DownloadDir = downloadDir
+	config.ListenAddr = *listenAddr
+	// announce the port we listen on
+	config.Port = 0
+	config.Limits = limits
+	c, err := client.NewClient(config)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	l, err := net.Listen("tcp", *rpcAddr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/rpc", rpc.NewServer(c))
+	mux.Handle("/transmission/rpc", rpc.NewTransmissionServer(c))
+	srv := &http.Server{Handler: mux}
+
+	log.Printf("accepting peers on %s, JSON-RPC API on http://%s/rpc, Transmission RPC on http://%s/transmission/rpc", c.Addr(), l.Addr(), l.Addr())
+	errc := make(chan error, 2)
+	go func() { errc <- srv.Serve(l) }()
+	if *watchDir != "" {
+		w := &watch.Watcher{
+			Dir:       *watchDir,
+			OutputDir: *watchOutput,
+			Client:    c,
+			Added: func(path string, t *client.Torrent, err error) {
+				if err != nil {
+					log.Printf("%s: %v", path, err)
+					return
+				}
+				log.Printf("%s: added %s", path, t.Name())
+			},
+		}
+		log.Printf("watching %s", *watchDir)
+		go func() {
+			if err := w.Run(ctx); err != nil {
+				errc <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Printf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
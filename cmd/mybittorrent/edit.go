@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/storage"
+)
+
+func runEdit(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	output := outputFlag(flags, "file to write the edited metainfo to, by default the torrent itself")
+	var announce, removeAnnounce, webSeeds, removeWebSeeds listFlag
+	flags.Var(&announce, "announce", "add a tier of trackers; repeat for more tiers and separate the URLs of one tier with commas")
+	flags.Var(&removeAnnounce, "remove-announce", "remove a tracker URL from every tier; may be repeated")
+	clearAnnounce := flags.Bool("clear-announce", false, "remove every tracker before adding those given with --announce")
+	flags.Var(&webSeeds, "web-seed", "add an HTTP seed URL; may be repeated")
+	flags.Var(&removeWebSeeds, "remove-web-seed", "remove an HTTP seed URL; may be repeated")
+	clearWebSeeds := flags.Bool("clear-web-seeds", false, "remove every HTTP seed before adding those given with --web-seed")
+	comment := flags.String("comment", "", "set the comment, or remove it if empty")
+	date := flags.String("creation-date", "", "set the creation date: \"now\", \"none\", seconds since the Unix epoch or RFC 3339")
+	private := flags.Bool("private", false, "set or, with --private=false, clear the private flag; changes the info hash")
+	source := flags.String("source", "", "set the source tag, or remove it if empty; changes the info hash")
+	pos, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	given := func(name string) bool {
+		found := false
+		flags.Visit(func(f *flag.Flag) { found = found || f.Name == name })
+		return found
+	}
+
+	data, err := os.ReadFile(pos[0])
+	if err != nil {
+		return err
+	}
+	torrentInfo, err := metainfo.FromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	var opts metainfo.EditOptions
+	if len(announce) > 0 || len(removeAnnounce) > 0 || *clearAnnounce {
+		var tiers [][]string
+		if !*clearAnnounce {
+			tiers = torrentTiers(torrentInfo)
+		}
+		for i, tier := range tiers {
+			tiers[i] = slices.DeleteFunc(tier, func(url string) bool { return slices.Contains(removeAnnounce, url) })
+		}
+		tiers = append(tiers, announceTiers(announce)...)
+		opts.AnnounceList = &tiers
+	}
+	if len(webSeeds) > 0 || len(removeWebSeeds) > 0 || *clearWebSeeds {
+		var seeds []string
+		if !*clearWebSeeds {
+			seeds = slices.DeleteFunc(torrentInfo.WebSeeds, func(url string) bool { return slices.Contains(removeWebSeeds, url) })
+		}
+		seeds = append(seeds, webSeeds...)
+		opts.WebSeeds = &seeds
+	}
+	if given("comment") {
+		opts.Comment = comment
+	}
+	if given("creation-date") {
+		creationDate, err := parseCreationDate(*date)
+		if err != nil {
+			return &usageError{err.Error()}
+		}
+		opts.CreationDate = &creationDate
+	}
+	if given("private") {
+		opts.Private = private
+	}
+	if given("source") {
+		opts.Source = source
+	}
+	if opts == (metainfo.EditOptions{}) {
+		flags.Usage()
+		return &usageError{"nothing to edit"}
+	}
+
+	edited, err := metainfo.Edit(data, opts)
+	if err != nil {
+		return err
+	}
+	editedInfo, err := metainfo.FromBytes(edited)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(editedInfo.InfoHash, torrentInfo.InfoHash) {
+		fmt.Fprintf(os.Stderr, "warning: the info hash changed from %x; peers of the original torrent will not see this one\n", torrentInfo.InfoHash)
+	}
+	path := *output
+	if path == "" {
+		path = pos[0]
+	}
+	if err := storage.WriteFile(path, edited); err != nil {
+		return err
+	}
+	fmt.Println("Edited:", path)
+	fmt.Printf("Info Hash: %x\n", editedInfo.InfoHash)
+	return nil
+}
+
+// torrentTiers returns the tiers of trackers of a torrent: its
+// announce-list, or its announce URL if it has none.
+func torrentTiers(t *metainfo.TorrentInfo) [][]string {
+	if len(t.AnnounceList) > 0 {
+		return t.AnnounceList
+	}
+	if t.TrackerURL != "~" {
+		return [][]string{{t.TrackerURL}}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+	"github.com/codecrafters-io/bittorrent-starter-go/storage"
+)
+
+func runEncode(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	output := outputFlag(flags, "file to write the bencoded value to instead of standard output")
+	pos, err := parseArgsRange(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	input, err := argOrStdin(pos)
+	if err != nil {
+		return err
+	}
+	val, err := bencode.FromJSON(input)
+	if err != nil {
+		return err
+	}
+	data, err := bencode.Encode(val)
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		return storage.WriteFile(*output, data)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// argOrStdin returns the only positional argument, or the content of
+// standard input if there is none.
+func argOrStdin(pos []string) ([]byte, error) {
+	if len(pos) == 1 {
+		return []byte(pos[0]), nil
+	}
+	return io.ReadAll(os.Stdin)
+}
@@ -1,386 +1,307 @@
 package main
 
 import (
-	"encoding/binary"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+// program is the name commands are documented with.
+const program = "./your_bittorrent.sh"
+
+// Exit codes.
+const (
+	exitFailure = 1
+	exitUsage   = 2
 )
 
-// Ensures gofmt doesn't remove the "os" encoding/json import (feel free to remove this!)
-var _ = json.Marshal
+// command is a subcommand of the CLI.
+type command struct {
+	name string
+	// args describes the positional arguments in the usage line.
+	args    string
+	summary string
+	run     func(ctx context.Context, cmd *command, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"decode", "[<bencoded-value>]", "decode a bencoded value, or standard input, and print it as JSON", runDecode},
+		{"encode", "[<json>]", "encode a JSON value, or standard input, as bencode", runEncode},
+		{"info", "<torrent>", "print the metainfo of a .torrent file", runInfo},
+		{"peers", "<torrent>", "print the peers the tracker returns for a torrent", runPeers},
+		{"handshake", "<torrent> <peer>", "handshake with a peer and print its peer ID", runHandshake},
+		{"download_piece", "-o <file> <torrent> <piece>", "download one piece of a torrent", runDownloadPiece},
+		{"download", "-o <path> <torrent>", "download a torrent", runDownload},
+		{"magnet_parse", "<magnet-link>", "print the tracker and info hash of a magnet link", runMagnetParse},
+		{"magnet_handshake", "<magnet-link>", "handshake with a peer of a magnet link and print its extension ID", runMagnetHandshake},
+		{"magnet_info", "<magnet-link>", "fetch and print the metainfo of a magnet link", runMagnetInfo},
+		{"magnet_download_piece", "-o <file> <magnet-link> <piece>", "download one piece of a magnet link", runMagnetDownloadPiece},
+		{"magnet_download", "-o <path> <magnet-link>", "download a magnet link", runMagnetDownload},
+		{"create", "<file-or-directory>", "create a .torrent file from local data", runCreate},
+		{"edit", "<torrent>", "change the trackers, web seeds and other fields of a .torrent file", runEdit},
+		{"magnet", "<torrent>", "print the magnet link of a .torrent file", runMagnet},
+		{"verify", "<torrent> <path>", "check data on disk against the piece hashes of a torrent", runVerify},
+		{"daemon", "[download-dir]", "run a session controlled through an RPC API", runDaemon},
+	}
+}
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("invalid arguments provided, there should be atleast three arguments")
-		return
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the command line args and returns the exit code.
+func run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+	name := args[0]
+	switch name {
+	case "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
+	case "help":
+		if len(args) == 1 {
+			printUsage(os.Stdout)
+			return 0
+		}
+		name, args = args[1], []string{args[1], "--help"}
 	}
-	command := os.Args[1]
-	switch command {
-	case "decode":
-		bencodedValue := os.Args[2]
-
-		decoded, err := decodeFromBytes([]byte(bencodedValue))
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
 
-		jsonOutput, _ := json.Marshal(decoded)
-		fmt.Println(string(jsonOutput))
-		return
-	case "info":
-		torrentInfo, err := getTorrentInfoFromFile(os.Args[2])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println("Tracker URL:", torrentInfo.TrackerURL)
-		fmt.Println("Length:", torrentInfo.FileLength)
-		fmt.Printf("Info Hash: %x\n", torrentInfo.InfoHash)
-		fmt.Printf("Piece Length: %d\n", torrentInfo.PieceLength)
-		fmt.Printf("Piece Hashes:\n")
-		for _, v := range torrentInfo.PieceHashes {
-			fmt.Println(v)
-		}
-		return
-	case "peers":
-		torrentInfo, err := getTorrentInfoFromFile(os.Args[2])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		u := getRequestUrlFromTorrentInfo(torrentInfo.TrackerURL, torrentInfo.InfoHash, torrentInfo.FileLength)
-		peerUrls, err := fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		for _, v := range peerUrls {
-			fmt.Println(v)
-		}
-		return
-	case "handshake":
-		torrentInfo, err := getTorrentInfoFromFile(os.Args[2])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		u := getRequestUrlFromTorrentInfo(torrentInfo.TrackerURL, torrentInfo.InfoHash, torrentInfo.FileLength)
-		_, err = fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		conn, peerId, err := connectWithPeer(os.Args[3], genPeerId(), torrentInfo.InfoHash, nil)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		defer conn.Close()
-		fmt.Printf("Peer ID: %x\n", peerId)
-		return
-	case "download_piece":
-		torrentInfo, err := getTorrentInfoFromFile(os.Args[4])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		u := getRequestUrlFromTorrentInfo(torrentInfo.TrackerURL, torrentInfo.InfoHash, torrentInfo.FileLength)
-		peerUrls, err := fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		clientId := genPeerId()
-		conn, _, err := connectWithPeer(peerUrls[0], clientId, torrentInfo.InfoHash, nil)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		defer conn.Close()
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return exitFailure
+	}
+	cfg = settings
 
-		if err := initiateRcvRequest(conn); err != nil {
-			fmt.Println("Error:", err)
-			return
-		}
-		index, _ := strconv.ParseInt(os.Args[5], 10, 32)
-		i := int(index)
-		fileData, err := downloadPiece(conn, torrentInfo.PieceLength, i, torrentInfo.FileLength)
-		if err != nil {
-			return
-		}
-		err = writeToDisk(os.Args[3], fileData)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = cmd.run(ctx, cmd, args[1:])
+	var usageErr *usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		// the flag set already printed the usage
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitUsage
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return exitFailure
+	}
+}
 
-		return
-	case "download":
-		torrentInfo, err := getTorrentInfoFromFile(os.Args[4])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		u := getRequestUrlFromTorrentInfo(torrentInfo.TrackerURL, torrentInfo.InfoHash, torrentInfo.FileLength)
-		peerUrls, err := fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
 		}
-		clientId := genPeerId()
-		connMap := make([]net.Conn, 0, len(peerUrls))
-		for _, peer := range peerUrls {
-			conn, _, err := connectWithPeer(peer, clientId, torrentInfo.InfoHash, nil)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
-			defer conn.Close()
+	}
+	return nil
+}
 
-			if err := initiateRcvRequest(conn); err != nil {
-				fmt.Println("Error:", err)
-				return
-			}
-			connMap = append(connMap, conn)
-		}
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [options] [arguments]\n\ncommands:\n", program)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-22s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun %s <command> --help for the options of a command.\n", program)
+}
 
-		fileData := make([]byte, 0)
-		fileMap := make(map[int][]byte)
-		wq := createWorkQueue(torrentInfo)
-		workers := createWorkers(torrentInfo, connMap, fileMap)
-		wPool := newWorkerPool(wq, workers...)
-		wPool.start()
-		for i := range torrentInfo.PieceHashes {
-			fileData = append(fileData, fileMap[i]...)
-		}
-		// fmt.Println(len(fileData))
-		err = writeToDisk(os.Args[3], fileData)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
+// usageError is an invalid command line. It makes the program exit with
+// exitUsage.
+type usageError struct {
+	msg string
+}
 
-		return
-	case "magnet_parse":
-		if len(os.Args) != 3 {
-			fmt.Println("usage: ./your_bittorrent.sh magnet_parse <magnet-link>")
-			os.Exit(1)
-		}
+func (e *usageError) Error() string {
+	return e.msg
+}
 
-		magnetLink := os.Args[2]
-		mag, err := parseMagentFromString(magnetLink)
-		if err != nil {
-			fmt.Println(err)
-			return
+// flagSet returns an empty flag set for the command whose usage describes
+// the command and its flags.
+func (cmd *command) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		w := flags.Output()
+		fmt.Fprintf(w, "usage: %s %s [options] %s\n\n%s.\n", program, cmd.name, cmd.args, upperFirst(cmd.summary))
+		if hasFlags(flags) {
+			fmt.Fprintln(w, "\noptions:")
+			flags.PrintDefaults()
 		}
+	}
+	return flags
+}
 
-		fmt.Println("Tracker URL:", mag["tr"])
-		fmt.Printf("Info Hash: %x\n", mag["xt"])
-		return
-	case "magnet_handshake":
-		if len(os.Args) != 3 {
-			fmt.Println("usage: ./your_bittorrent.sh magnet_parse <magnet-link>")
-			os.Exit(1)
-		}
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
 
-		magnetLink := os.Args[2]
-		mag, err := parseMagentFromString(magnetLink)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		infoHash := mag["xt"]
-		u := getRequestUrlFromTorrentInfo(mag["tr"], []byte(infoHash), -1)
-		peerUrls, err := fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		clientId := genPeerId()
-		conn, peerId, err := connectWithPeer(peerUrls[0], clientId, []byte(infoHash), enableMagnetExtension())
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Printf("Peer ID: %x\n", peerId)
-		decoded, err := getMagnetExtensionPayload(conn)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		metadataExtId := decoded.(map[string]interface{})["m"].(map[string]interface{})["ut_metadata"].(int)
-		fmt.Printf("Peer Metadata Extension ID: %d\n", metadataExtId)
-		return
-	case "magnet_info":
-		if len(os.Args) != 3 {
-			fmt.Println("usage: ./your_bittorrent.sh magnet_parse <magnet-link>")
-			os.Exit(1)
-		}
+func hasFlags(flags *flag.FlagSet) bool {
+	found := false
+	flags.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
 
-		magnetLink := os.Args[2]
-		mag, err := parseMagentFromString(magnetLink)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		infoHash := mag["xt"]
-		u := getRequestUrlFromTorrentInfo(mag["tr"], []byte(infoHash), -1)
-		peerUrls, err := fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		clientId := genPeerId()
-		conn, _, err := connectWithPeer(peerUrls[0], clientId, []byte(infoHash), enableMagnetExtension())
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		decoded, err := getMagnetExtensionPayload(conn)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		metadataExtId := decoded.(map[string]interface{})["m"].(map[string]interface{})["ut_metadata"].(int)
-		metaExtIdByteArr := make([]byte, 4)
-		binary.BigEndian.PutUint32(metaExtIdByteArr, uint32(metadataExtId))
-		torrentInfo, err := getMagnetRequestMetadata(conn, metaExtIdByteArr[3])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		torrentInfo.TrackerURL = mag["tr"]
-		fmt.Println("Tracker URL:", torrentInfo.TrackerURL)
-		fmt.Println("Length:", torrentInfo.FileLength)
-		fmt.Printf("Info Hash: %x\n", torrentInfo.InfoHash)
-		fmt.Printf("Piece Length: %d\n", torrentInfo.PieceLength)
-		fmt.Printf("Piece Hashes:\n")
-		for _, v := range torrentInfo.PieceHashes {
-			fmt.Println(v)
-		}
-		return
-	case "magnet_download_piece":
-		if len(os.Args) != 6 {
-			fmt.Println("usage: ./your_bittorent.sh magnet_download_piece -o /tmp/test-piece-0 <magnet_link> 0")
-			os.Exit(1)
-		}
+// parseArgs parses args with flags, which may be mixed with the positional
+// arguments, and returns the positional arguments. It fails unless there
+// are exactly n of them.
+func parseArgs(flags *flag.FlagSet, args []string, n int) ([]string, error) {
+	return parseArgsRange(flags, args, n, n)
+}
 
-		magnetLink := os.Args[4]
-		mag, err := parseMagentFromString(magnetLink)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		infoHash := mag["xt"]
-		u := getRequestUrlFromTorrentInfo(mag["tr"], []byte(infoHash), -1)
-		peerUrls, err := fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		clientId := genPeerId()
-		conn, _, err := connectWithPeer(peerUrls[0], clientId, []byte(infoHash), enableMagnetExtension())
-		if err != nil {
-			fmt.Println(err)
-			return
+// parseArgsRange is parseArgs for commands taking between min and max
+// positional arguments.
+func parseArgsRange(flags *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, &usageError{err.Error()}
 		}
-		decoded, err := getMagnetExtensionPayload(conn)
-		if err != nil {
-			fmt.Println(err)
-			return
+		rest := flags.Args()
+		if len(rest) == 0 {
+			break
 		}
-		metadataExtId := decoded.(map[string]interface{})["m"].(map[string]interface{})["ut_metadata"].(int)
-		metaExtIdByteArr := make([]byte, 4)
-		binary.BigEndian.PutUint32(metaExtIdByteArr, uint32(metadataExtId))
-		torrentInfo, err := getMagnetRequestMetadata(conn, metaExtIdByteArr[3])
-		if err != nil {
-			fmt.Println(err)
-			return
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			// everything after "--" is positional
+			positional = append(positional, rest...)
+			break
 		}
-		torrentInfo.TrackerURL = mag["tr"]
-		if err := sendInterestedMsg(conn); err != nil {
-			fmt.Println("Error:", err)
-			return
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	if len(positional) < min || len(positional) > max {
+		flags.Usage()
+		want := fmt.Sprint(min)
+		if max > min {
+			want = fmt.Sprintf("%d to %d", min, max)
 		}
-		index, _ := strconv.ParseInt(os.Args[5], 10, 32)
-		i := int(index)
+		return nil, &usageError{fmt.Sprintf("wrong number of arguments: want %s, got %d", want, len(positional))}
+	}
+	return positional, nil
+}
 
-		fileData, err := downloadPiece(conn, torrentInfo.PieceLength, i, torrentInfo.FileLength)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		err = writeToDisk(os.Args[3], fileData)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		return
-	case "magnet_download":
-		if len(os.Args) != 5 {
-			fmt.Println("usage: ./your_bittorent.sh magnet_download -o /tmp/sample <magnet-link>")
-			os.Exit(1)
-		}
+// networkOptions are the options of the commands that talk to trackers and
+// peers.
+type networkOptions struct {
+	port    int
+	timeout time.Duration
+	// peerLimit caps the peers a command uses, 0 for no limit.
+	peerLimit int
+	// peers are the addresses given with --peer.
+	peers peerFlag
+}
 
-		magnetLink := os.Args[4]
-		mag, err := parseMagentFromString(magnetLink)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		infoHash := mag["xt"]
-		u := getRequestUrlFromTorrentInfo(mag["tr"], []byte(infoHash), -1)
-		peerUrls, err := fetchPeersFromTorrentUrl(u)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		clientId := genPeerId()
-		conn, _, err := connectWithPeer(peerUrls[0], clientId, []byte(infoHash), enableMagnetExtension())
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		decoded, err := getMagnetExtensionPayload(conn)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		metadataExtId := decoded.(map[string]interface{})["m"].(map[string]interface{})["ut_metadata"].(int)
-		metaExtIdByteArr := make([]byte, 4)
-		binary.BigEndian.PutUint32(metaExtIdByteArr, uint32(metadataExtId))
-		torrentInfo, err := getMagnetRequestMetadata(conn, metaExtIdByteArr[3])
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		torrentInfo.TrackerURL = mag["tr"]
-		if err := sendInterestedMsg(conn); err != nil {
-			fmt.Println("Error:", err)
-			return
-		}
-		fileData := make([]byte, 0)
-		for i := range torrentInfo.PieceHashes {
-			pieceData, err := downloadPiece(conn, torrentInfo.PieceLength, i, torrentInfo.FileLength)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
+func (o *networkOptions) register(flags *flag.FlagSet) {
+	flags.IntVar(&o.port, "port", cfg.Port, "port announced to trackers")
+	o.registerTimeout(flags)
+	flags.IntVar(&o.peerLimit, "peer-limit", cfg.MaxPeers, "maximum number of peers to use, 0 for no limit")
+}
 
-			fileData = append(fileData, pieceData...)
-		}
-		err = writeToDisk(os.Args[3], fileData)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		return
-	default:
-		fmt.Println("unsupported command", command)
-		return
+// registerTimeout registers only --timeout, for commands that talk to a
+// single peer they are given and never announce.
+func (o *networkOptions) registerTimeout(flags *flag.FlagSet) {
+	flags.DurationVar(&o.timeout, "timeout", 0, "give up after this long, such as 30s or 5m; 0 for no limit")
+}
+
+// registerPeers registers the --peer option of the commands that download.
+func (o *networkOptions) registerPeers(flags *flag.FlagSet) {
+	flags.Var(&o.peers, "peer", "peer to connect to, as host:port, which makes the tracker optional; may be repeated")
+}
+
+// peerFlag is a repeatable flag holding "host:port" peer addresses.
+type peerFlag []string
+
+func (p *peerFlag) String() string {
+	return strings.Join(*p, " ")
+}
+
+func (p *peerFlag) Set(v string) error {
+	if _, _, err := net.SplitHostPort(v); err != nil {
+		return err
+	}
+	*p = append(*p, v)
+	return nil
+}
+
+// context returns ctx bounded by the timeout option.
+func (o *networkOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, o.timeout)
+}
+
+// outputFlag registers the -o and --output flags, which both set the output
+// path.
+func outputFlag(flags *flag.FlagSet, usage string) *string {
+	output := flags.String("output", "", usage)
+	flags.StringVar(output, "o", "", "shorthand for --output")
+	return output
+}
+
+// requireOutput fails if the output option was not given.
+func requireOutput(flags *flag.FlagSet, output string) error {
+	if output == "" {
+		flags.Usage()
+		return &usageError{"missing -o/--output"}
+	}
+	return nil
+}
+
+func printTorrentInfo(torrentInfo *metainfo.TorrentInfo) {
+	fmt.Println("Tracker URL:", torrentInfo.TrackerURL)
+	fmt.Println("Length:", torrentInfo.FileLength)
+	fmt.Printf("Info Hash: %x\n", torrentInfo.InfoHash)
+	fmt.Printf("Piece Length: %d\n", torrentInfo.PieceLength)
+	fmt.Printf("Piece Hashes:\n")
+	for _, v := range torrentInfo.PieceHashes {
+		fmt.Println(v)
+	}
+}
+
+// runTorrent downloads t while showing its progress, and stops the client
+// once it completed, failed or ctx was cancelled.
+func runTorrent(ctx context.Context, c *client.Client, t *client.Torrent) error {
+	defer c.Close()
+	if err := t.Start(); err != nil {
+		return err
 	}
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		showProgress(progressCtx, t)
+	}()
+	err := t.Wait(ctx)
+	stopProgress()
+	<-progressDone
+	return err
 }
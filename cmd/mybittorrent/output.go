@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/magnet"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/tracker"
+)
+
+// The types below are the schema of the --json output. Every field is
+// always present; values that are unknown are null. Hashes and peer IDs
+// are hex encoded.
+
+type torrentJSON struct {
+	InfoHash string `json:"info_hash"`
+	// Name is null if the torrent has none.
+	Name *string `json:"name"`
+	// Announce is null if the torrent has no tracker.
+	Announce     *string    `json:"announce"`
+	AnnounceList [][]string `json:"announce_list"`
+	WebSeeds     []string   `json:"web_seeds"`
+	// CreationDate is in seconds since the Unix epoch.
+	CreationDate *int64     `json:"creation_date"`
+	Comment      string     `json:"comment"`
+	CreatedBy    string     `json:"created_by"`
+	Private      bool       `json:"private"`
+	Source       string     `json:"source"`
+	Length       int64      `json:"length"`
+	MultiFile    bool       `json:"multi_file"`
+	Files        []fileJSON `json:"files"`
+	PieceLength  int        `json:"piece_length"`
+	PieceHashes  []string   `json:"piece_hashes"`
+}
+
+type fileJSON struct {
+	// Path is relative to the torrent's directory, with "/" separators.
+	Path   string `json:"path"`
+	Length int64  `json:"length"`
+	Offset int64  `json:"offset"`
+}
+
+type peersJSON struct {
+	// Interval is the announce interval the tracker asked for, in seconds.
+	Interval int        `json:"interval"`
+	Peers    []peerJSON `json:"peers"`
+}
+
+type peerJSON struct {
+	Addr string `json:"addr"`
+	// PeerID is only known from a handshake or a non-compact tracker
+	// reply.
+	PeerID *string `json:"peer_id"`
+}
+
+type magnetJSON struct {
+	InfoHash string `json:"info_hash"`
+	// Name is the display name, or null if the link has none.
+	Name *string `json:"name"`
+	// Length is null if the link does not give it.
+	Length   *int64   `json:"length"`
+	Trackers []string `json:"trackers"`
+	WebSeeds []string `json:"web_seeds"`
+	Peers    []string `json:"peers"`
+	Keywords []string `json:"keywords"`
+	// SelectOnly holds the indices of the files to download, or null for
+	// every file.
+	SelectOnly []int `json:"select_only"`
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// optional returns nil for an empty string, which is encoded as null.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func torrentInfoJSON(info *metainfo.TorrentInfo) torrentJSON {
+	t := torrentJSON{
+		InfoHash:     hex.EncodeToString(info.InfoHash),
+		AnnounceList: info.AnnounceList,
+		WebSeeds:     info.WebSeeds,
+		Comment:      info.Comment,
+		CreatedBy:    info.CreatedBy,
+		Private:      info.Private,
+		Source:       info.Source,
+		Length:       info.FileLength,
+		MultiFile:    info.MultiFile,
+		Files:        make([]fileJSON, 0, len(info.Files)),
+		PieceLength:  info.PieceLength,
+		PieceHashes:  info.PieceHashes,
+	}
+	if info.Name != "~" {
+		t.Name = optional(info.Name)
+	}
+	if info.TrackerURL != "~" {
+		t.Announce = optional(info.TrackerURL)
+	}
+	if t.AnnounceList == nil {
+		t.AnnounceList = [][]string{}
+	}
+	if t.WebSeeds == nil {
+		t.WebSeeds = []string{}
+	}
+	if !info.CreationDate.IsZero() {
+		date := info.CreationDate.Unix()
+		t.CreationDate = &date
+	}
+	for _, f := range info.Files {
+		t.Files = append(t.Files, fileJSON{
+			Path:   strings.Join(f.Path, "/"),
+			Length: f.Length,
+			Offset: f.Offset,
+		})
+	}
+	return t
+}
+
+func trackerPeersJSON(resp *tracker.AnnounceResponse) peersJSON {
+	p := peersJSON{Interval: resp.Interval, Peers: make([]peerJSON, 0, len(resp.Peers))}
+	for _, addr := range resp.Peers {
+		peer := peerJSON{Addr: addr}
+		if id, ok := resp.PeerIDs[addr]; ok {
+			peer.PeerID = optional(hex.EncodeToString([]byte(id)))
+		}
+		p.Peers = append(p.Peers, peer)
+	}
+	return p
+}
+
+func magnetLinkJSON(mag *magnet.Link) magnetJSON {
+	m := magnetJSON{
+		InfoHash:   hex.EncodeToString(mag.InfoHash),
+		Name:       optional(mag.Name),
+		Trackers:   nonNil(mag.Trackers),
+		WebSeeds:   nonNil(mag.WebSeeds),
+		Peers:      nonNil(mag.Peers),
+		Keywords:   nonNil(mag.Keywords),
+		SelectOnly: mag.SelectOnly,
+	}
+	if mag.Length > 0 {
+		m.Length = &mag.Length
+	}
+	return m
+}
+
+// nonNil returns an empty slice for a nil one, which is encoded as [].
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// stringsToJSON returns a decoded bencode value with its byte strings
+// converted to strings, so they are printed as JSON strings rather than
+// base64.
+func stringsToJSON(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = stringsToJSON(e)
+		}
+		return list
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			dict[k] = stringsToJSON(e)
+		}
+		return dict
+	}
+	return val
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+)
+
+const (
+	// redrawInterval is how often the terminal progress view is redrawn.
+	redrawInterval = 500 * time.Millisecond
+	// logInterval is how often a progress line is logged when stdout is not
+	// a terminal.
+	logInterval = 5 * time.Second
+	// pieceMapWidth is the number of cells of the piece map.
+	pieceMapWidth = 50
+	// maxListedPeers bounds the number of peers shown in the view.
+	maxListedPeers = 8
+)
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// showProgress reports the progress of t until ctx is done. On a terminal
+// a view is redrawn in place on stdout; otherwise a line is logged to
+// stderr every few seconds, so stdout stays free for the command's output.
+func showProgress(ctx context.Context, t *client.Torrent) {
+	if !isTerminal(os.Stdout) {
+		ticker := time.NewTicker(logInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				log.Print(progressLine(t))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	view := &progressView{w: os.Stdout}
+	ticker := time.NewTicker(redrawInterval)
+	defer ticker.Stop()
+	for {
+		view.draw(t)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			view.draw(t)
+			return
+		}
+	}
+}
+
+// progressLine returns a one-line summary of the progress of t.
+func progressLine(t *client.Torrent) string {
+	s := t.Stats()
+	if s.PiecesTotal == 0 {
+		return fmt.Sprintf("%s: fetching metadata, %d peers", t.Name(), s.Peers)
+	}
+	return fmt.Sprintf("%s: %s, %d/%d pieces, down %s, up %s, ETA %s, %d peers",
+		t.Name(), formatPercent(s), s.PiecesVerified, s.PiecesTotal,
+		formatRate(s.DownloadRate), formatRate(s.UploadRate), formatETA(s), s.Peers)
+}
+
+// progressView draws the progress of a torrent on a terminal, replacing
+// what it drew before.
+type progressView struct {
+	w io.Writer
+	// lines is the number of lines drawn last time.
+	lines int
+}
+
+func (v *progressView) draw(t *client.Torrent) {
+	s := t.Stats()
+	lines := []string{fmt.Sprintf("%s  %s", t.Name(), s.State)}
+	if s.PiecesTotal == 0 {
+		lines = append(lines, "fetching metadata")
+	} else {
+		lines = append(lines,
+			fmt.Sprintf("[%s] %s  %d/%d pieces", pieceMap(t.VerifiedPieces()), formatPercent(s), s.PiecesVerified, s.PiecesTotal),
+			fmt.Sprintf("down %s  up %s  ETA %s", formatRate(s.DownloadRate), formatRate(s.UploadRate), formatETA(s)))
+	}
+	peers := t.Peers()
+	lines = append(lines, fmt.Sprintf("%d peers", len(peers)))
+	for i, p := range peers {
+		if i == maxListedPeers {
+			lines = append(lines, fmt.Sprintf("  and %d more", len(peers)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %-21s  down %-11s  up %s", p.Addr, formatRate(p.DownloadRate), formatRate(p.UploadRate)))
+	}
+
+	var b strings.Builder
+	if v.lines > 0 {
+		// move to the first line drawn last time and clear the rest
+		fmt.Fprintf(&b, "\x1b[%dA", v.lines)
+	}
+	b.WriteString("\r\x1b[J")
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	io.WriteString(v.w, b.String())
+	v.lines = len(lines)
+}
+
+// pieceMap renders the verified pieces in pieceMapWidth cells. A cell is
+// full if all of its pieces are verified, and shaded if some are.
+func pieceMap(verified []bool) string {
+	cells := min(pieceMapWidth, len(verified))
+	var b strings.Builder
+	for i := range cells {
+		from := i * len(verified) / cells
+		to := (i + 1) * len(verified) / cells
+		done := 0
+		for _, ok := range verified[from:to] {
+			if ok {
+				done++
+			}
+		}
+		switch {
+		case done == to-from:
+			b.WriteString("█")
+		case done > 0:
+			b.WriteString("▒")
+		default:
+			b.WriteString("░")
+		}
+	}
+	return b.String()
+}
+
+// formatPercent returns how much of the wanted data is complete.
+func formatPercent(s client.Stats) string {
+	if s.BytesWanted == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(s.BytesWanted-s.BytesLeft)/float64(s.BytesWanted))
+}
+
+// formatETA returns the time left at the current download rate.
+func formatETA(s client.Stats) string {
+	if s.BytesLeft == 0 {
+		return "0s"
+	}
+	if s.DownloadRate == 0 {
+		return "unknown"
+	}
+	return (time.Duration(s.BytesLeft/int64(s.DownloadRate)) * time.Second).String()
+}
+
+// formatRate formats a rate in bytes per second with a binary unit.
+func formatRate(rate int) string {
+	const units = "KMGT"
+	if rate < 1024 {
+		return fmt.Sprintf("%d B/s", rate)
+	}
+	r := float64(rate) / 1024
+	i := 0
+	for r >= 1024 && i < len(units)-1 {
+		r /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB/s", r, units[i])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+type verifyJSON struct {
+	Pieces  int   `json:"pieces"`
+	Good    int   `json:"good"`
+	Bad     []int `json:"bad"`
+	Missing []int `json:"missing"`
+}
+
+func runVerify(ctx context.Context, cmd *command, args []string) error {
+	flags := cmd.flagSet()
+	jsonOutput := jsonFlag(flags)
+	workers := flags.Int("workers", 0, "number of pieces hashed in parallel, 0 for one per CPU")
+	pos, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	torrentInfo, err := metainfo.FromFile(pos[0])
+	if err != nil {
+		return err
+	}
+	states, err := torrentInfo.Verify(pos[1], *workers)
+	if err != nil {
+		return err
+	}
+
+	result := verifyJSON{Pieces: len(states), Bad: []int{}, Missing: []int{}}
+	for i, state := range states {
+		switch state {
+		case metainfo.PieceGood:
+			result.Good++
+		case metainfo.PieceBad:
+			result.Bad = append(result.Bad, i)
+		case metainfo.PieceMissing:
+			result.Missing = append(result.Missing, i)
+		}
+	}
+	if *jsonOutput {
+		printJSON(result)
+	} else {
+		fmt.Printf("Good: %d/%d\n", result.Good, result.Pieces)
+		fmt.Printf("Bad: %d%s\n", len(result.Bad), formatPieces(result.Bad))
+		fmt.Printf("Missing: %d%s\n", len(result.Missing), formatPieces(result.Missing))
+	}
+	if result.Good != result.Pieces {
+		return fmt.Errorf("%d of %d pieces did not verify", result.Pieces-result.Good, result.Pieces)
+	}
+	return nil
+}
+
+// formatPieces formats sorted piece indexes as ranges, such as
+// " (pieces 0-3, 7)".
+func formatPieces(pieces []int) string {
+	if len(pieces) == 0 {
+		return ""
+	}
+	var ranges []string
+	for i := 0; i < len(pieces); {
+		j := i
+		for j+1 < len(pieces) && pieces[j+1] == pieces[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, fmt.Sprint(pieces[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", pieces[i], pieces[j]))
+		}
+		i = j + 1
+	}
+	if len(pieces) == 1 {
+		return fmt.Sprintf(" (piece %d)", pieces[0])
+	}
+	return fmt.Sprintf(" (pieces %s)", strings.Join(ranges, ", "))
+}
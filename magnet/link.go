@@ -0,0 +1,128 @@
+package magnet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+// Link holds the parameters of a magnet link.
+type Link struct {
+	// InfoHash is the 20-byte SHA-1 info hash.
+	InfoHash []byte
+	// Name is the display name, "dn".
+	Name string
+	// Length is the total length in bytes, "xl", or zero if unknown.
+	Length int64
+	// Trackers holds the tracker URLs, "tr".
+	Trackers []string
+	// WebSeeds holds the HTTP seed URLs, "ws".
+	WebSeeds []string
+	// Peers holds the "host:port" addresses of peers to contact directly,
+	// "x.pe".
+	Peers []string
+	// Keywords holds the search keywords, "kt".
+	Keywords []string
+	// SelectOnly holds the indices of the files to download, "so" (BEP
+	// 53), or nil for every file.
+	SelectOnly []int
+}
+
+// Tracker returns the first tracker URL of l, or "" if it has none.
+func (l *Link) Tracker() string {
+	if len(l.Trackers) == 0 {
+		return ""
+	}
+	return l.Trackers[0]
+}
+
+// FromTorrent returns the magnet link of a torrent, with every tracker of
+// its announce URL and announce-list and its web seeds.
+func FromTorrent(t *metainfo.TorrentInfo) *Link {
+	l := &Link{
+		InfoHash: t.InfoHash,
+		Length:   t.FileLength,
+		WebSeeds: t.WebSeeds,
+	}
+	if t.Name != "~" {
+		l.Name = t.Name
+	}
+	if t.TrackerURL != "~" {
+		l.Trackers = append(l.Trackers, t.TrackerURL)
+	}
+	for _, tier := range t.AnnounceList {
+		for _, tr := range tier {
+			if !slices.Contains(l.Trackers, tr) {
+				l.Trackers = append(l.Trackers, tr)
+			}
+		}
+	}
+	return l
+}
+
+// String returns the magnet URI of l with its parameters percent-encoded.
+func (l *Link) String() string {
+	var b strings.Builder
+	b.WriteString("magnet:?xt=urn:btih:")
+	b.WriteString(hex.EncodeToString(l.InfoHash))
+	param := func(key, val string) {
+		b.WriteString("&" + key + "=" + escape(val))
+	}
+	if l.Name != "" {
+		param("dn", l.Name)
+	}
+	if l.Length > 0 {
+		param("xl", strconv.FormatInt(l.Length, 10))
+	}
+	for _, tr := range l.Trackers {
+		param("tr", tr)
+	}
+	for _, ws := range l.WebSeeds {
+		param("ws", ws)
+	}
+	for _, pe := range l.Peers {
+		param("x.pe", pe)
+	}
+	if len(l.Keywords) > 0 {
+		keywords := make([]string, len(l.Keywords))
+		for i, kt := range l.Keywords {
+			keywords[i] = escape(kt)
+		}
+		b.WriteString("&kt=" + strings.Join(keywords, "+"))
+	}
+	if len(l.SelectOnly) > 0 {
+		b.WriteString("&so=" + selectOnlyString(l.SelectOnly))
+	}
+	return b.String()
+}
+
+// escape percent-encodes a parameter value. A "+" is read as a space by
+// some clients and as a plus sign by others, so spaces are written as %20
+// and plus signs as %2B.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// selectOnlyString writes file indices in the form of the "so" parameter,
+// joining consecutive indices into ranges.
+func selectOnlyString(indices []int) string {
+	var parts []string
+	for i := 0; i < len(indices); {
+		j := i
+		for j+1 < len(indices) && indices[j+1] == indices[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", indices[i], indices[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(indices[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,141 @@
+// Package magnet parses and builds magnet links.
+package magnet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a magnet link. Its info hash is taken from the "xt"
+// parameter in its "urn:btih:" form, as 40 hex or 32 base32 digits. Each
+// parameter is unescaped on its own, so escaped "&" and "=" in tracker
+// URLs are kept, and repeated parameters such as "tr" are all kept. A "+"
+// is kept as well, except in "kt" where it separates the keywords and in
+// "dn" where it stands for a space, as in a form-encoded query. Unknown
+// parameters are ignored.
+func Parse(m string) (*Link, error) {
+	query, ok := strings.CutPrefix(m, "magnet:?")
+	if !ok {
+		return nil, fmt.Errorf("invalid magnet link")
+	}
+	l := &Link{}
+	for _, param := range strings.Split(query, "&") {
+		if param == "" {
+			continue
+		}
+		key, rawVal, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid magnet link parameter %q", param)
+		}
+		key, err := url.PathUnescape(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid magnet link parameter %q: %v", param, err)
+		}
+		val, err := url.PathUnescape(rawVal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid magnet link parameter %q: %v", param, err)
+		}
+		// Some links number repeated parameters, as in "tr.1" and "tr.2"
+		// or "x.pe.1".
+		if i := strings.LastIndex(key, "."); i > 0 {
+			if _, err := strconv.Atoi(key[i+1:]); err == nil {
+				key = key[:i]
+			}
+		}
+
+		switch key {
+		case "xt":
+			hash, ok := strings.CutPrefix(val, "urn:btih:")
+			if !ok {
+				// Other hashes, such as BitTorrent v2 "urn:btmh:", are
+				// not supported.
+				continue
+			}
+			if l.InfoHash, err = parseInfoHash(hash); err != nil {
+				return nil, err
+			}
+		case "dn":
+			// rawVal was unescaped above, so this cannot fail
+			l.Name, _ = url.QueryUnescape(rawVal)
+		case "xl":
+			if l.Length, err = strconv.ParseInt(val, 10, 64); err != nil || l.Length < 0 {
+				return nil, fmt.Errorf("invalid magnet link length %q", val)
+			}
+		case "tr":
+			l.Trackers = append(l.Trackers, val)
+		case "ws":
+			l.WebSeeds = append(l.WebSeeds, val)
+		case "x.pe":
+			if _, _, err := net.SplitHostPort(val); err != nil {
+				return nil, fmt.Errorf("invalid magnet link peer %q: %v", val, err)
+			}
+			l.Peers = append(l.Peers, val)
+		case "kt":
+			for _, kt := range strings.Split(rawVal, "+") {
+				// kt was unescaped as a whole above, so this cannot fail
+				if kt, _ = url.PathUnescape(kt); kt != "" {
+					l.Keywords = append(l.Keywords, kt)
+				}
+			}
+		case "so":
+			if l.SelectOnly, err = parseSelectOnly(val); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if l.InfoHash == nil {
+		return nil, fmt.Errorf("magnet link has no BitTorrent info hash")
+	}
+	return l, nil
+}
+
+// parseInfoHash decodes an info hash in its hex or base32 form.
+func parseInfoHash(s string) ([]byte, error) {
+	var h []byte
+	var err error
+	switch len(s) {
+	case 40:
+		h, err = hex.DecodeString(s)
+	case 32:
+		h, err = base32.StdEncoding.DecodeString(strings.ToUpper(s))
+	default:
+		err = fmt.Errorf("wrong length")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid magnet link info hash %q: %v", s, err)
+	}
+	return h, nil
+}
+
+// maxSelectOnly bounds the number of file indices a magnet link selects,
+// as a range such as "0-999999999" would otherwise exhaust memory.
+const maxSelectOnly = 1 << 16
+
+// parseSelectOnly parses the file indices of the BEP 53 "so" parameter, a
+// comma-separated list of indices and inclusive ranges such as "0,2,4-6".
+func parseSelectOnly(s string) ([]int, error) {
+	var indices []int
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(first)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(last)
+		}
+		if err != nil || from < 0 || to < from {
+			return nil, fmt.Errorf("invalid magnet link file selection %q", s)
+		}
+		if len(indices)+to-from >= maxSelectOnly {
+			return nil, fmt.Errorf("magnet link file selection %q is too large", s)
+		}
+		for i := from; i <= to; i++ {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,187 @@
+package magnet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+var testHash, _ = hex.DecodeString("8ccdc0ca995268193f0a4c5ea823004b34b2370f")
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want *Link
+	}{
+		{
+			name: "hex hash",
+			link: "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&dn=sample.txt&tr=http%3A%2F%2Ftracker.example%2Fannounce",
+			want: &Link{InfoHash: testHash, Name: "sample.txt", Trackers: []string{"http://tracker.example/announce"}},
+		},
+		{
+			name: "base32 hash",
+			link: "magnet:?xt=urn:btih:RTG4BSUZKJUBSPYKJRPKQIYAJM2LENYP",
+			want: &Link{InfoHash: testHash},
+		},
+		{
+			name: "repeated and numbered trackers",
+			link: "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&tr=udp://a:1&tr.1=udp://b:2&tr=udp://c:3",
+			want: &Link{InfoHash: testHash, Trackers: []string{"udp://a:1", "udp://b:2", "udp://c:3"}},
+		},
+		{
+			name: "escaped query in tracker",
+			link: "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&tr=http://x/a%3Fk%3D1%26p%3Da+b",
+			want: &Link{InfoHash: testHash, Trackers: []string{"http://x/a?k=1&p=a+b"}},
+		},
+		{
+			name: "plus sign kept",
+			link: "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&ws=http://x/a+b&tr=udp://a+b:1",
+			want: &Link{InfoHash: testHash, Trackers: []string{"udp://a+b:1"}, WebSeeds: []string{"http://x/a+b"}},
+		},
+		{
+			name: "plus sign as a space in the name",
+			link: "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&dn=Ubuntu+22.04+a%2Bb%20c",
+			want: &Link{InfoHash: testHash, Name: "Ubuntu 22.04 a+b c"},
+		},
+		{
+			name: "numbered peers and web seeds",
+			link: "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&x.pe.1=10.0.0.1:6881&x.pe.2=[::1]:6881&ws.1=http://x/&x.pe=10.0.0.2:1",
+			want: &Link{InfoHash: testHash, Peers: []string{"10.0.0.1:6881", "[::1]:6881", "10.0.0.2:1"}, WebSeeds: []string{"http://x/"}},
+		},
+		{
+			name: "every parameter",
+			link: "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&xl=92063&x.pe=10.0.0.1:6881&x.pe=[::1]:6881&kt=foo+bar%2Bbaz+a%20b&so=0,2,4-6&unknown=1",
+			want: &Link{
+				InfoHash:   testHash,
+				Length:     92063,
+				Peers:      []string{"10.0.0.1:6881", "[::1]:6881"},
+				Keywords:   []string{"foo", "bar+baz", "a b"},
+				SelectOnly: []int{0, 2, 4, 5, 6},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.link)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+	}{
+		{"not a magnet link", "http://example.com/?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f"},
+		{"no info hash", "magnet:?dn=x"},
+		{"only a v2 hash", "magnet:?xt=urn:btmh:1220aaaa"},
+		{"short hash", "magnet:?xt=urn:btih:8ccdc0ca"},
+		{"invalid hex", "magnet:?xt=urn:btih:zzcdc0ca995268193f0a4c5ea823004b34b2370f"},
+		{"parameter without value", "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&dn"},
+		{"invalid escape", "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&dn=%zz"},
+		{"peer without port", "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&x.pe=10.0.0.1"},
+		{"negative length", "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&xl=-1"},
+		{"reversed range", "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&so=3-1"},
+		{"huge range", "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&so=0-999999999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if l, err := Parse(tt.link); err == nil {
+				t.Errorf("Parse() = %+v, want an error", l)
+			}
+		})
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	links := []*Link{
+		{InfoHash: testHash},
+		{
+			InfoHash:   testHash,
+			Name:       "a b+c&d=e/ü",
+			Length:     1 << 40,
+			Trackers:   []string{"http://x/announce?k=1&p=a+b", "udp://y:80"},
+			WebSeeds:   []string{"https://mirror.example/a b/"},
+			Peers:      []string{"10.0.0.1:6881", "[::1]:6881"},
+			Keywords:   []string{"foo", "bar+baz", "a b"},
+			SelectOnly: []int{0, 2, 3, 4, 9},
+		},
+	}
+	for _, l := range links {
+		s := l.String()
+		got, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", s, err)
+		}
+		if !reflect.DeepEqual(got, l) {
+			t.Errorf("Parse(%q) = %+v, want %+v", s, got, l)
+		}
+	}
+}
+
+func TestStringEscaping(t *testing.T) {
+	l := &Link{InfoHash: testHash, Name: "a b+c", SelectOnly: []int{0, 2, 3, 4}}
+	want := "magnet:?xt=urn:btih:8ccdc0ca995268193f0a4c5ea823004b34b2370f&dn=a%20b%2Bc&so=0,2-4"
+	if got := l.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestFromTorrentRoundTrip(t *testing.T) {
+	bstr := func(s string) string { return fmt.Sprintf("%d:%s", len(s), s) }
+	info := "d6:lengthi92063e4:name" + bstr("a b+c&d=e ü.txt") + "12:piece lengthi16384e6:pieces" + bstr(strings.Repeat("a", 120)) + "e"
+	tests := []struct {
+		name    string
+		torrent string
+		want    Link
+	}{
+		{
+			"every field",
+			"d8:announce" + bstr("http://x/announce?k=1&p=a+b") +
+				"13:announce-listll" + bstr("http://x/announce?k=1&p=a+b") + bstr("udp://y:80") + "el" + bstr("udp://z:80") + "ee" +
+				"4:info" + info +
+				"8:url-listl" + bstr("https://mirror.example/a b/") + "ee",
+			Link{
+				Name:     "a b+c&d=e ü.txt",
+				Length:   92063,
+				Trackers: []string{"http://x/announce?k=1&p=a+b", "udp://y:80", "udp://z:80"},
+				WebSeeds: []string{"https://mirror.example/a b/"},
+			},
+		},
+		{
+			"no name or tracker",
+			"d4:infod6:lengthi3e12:piece lengthi16384e6:pieces" + bstr(strings.Repeat("a", 20)) + "ee",
+			Link{Length: 3},
+		},
+	}
+	for _, tt := range tests {
+		torrent, err := metainfo.FromBytes([]byte(tt.torrent))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		s := FromTorrent(torrent).String()
+		got, err := Parse(s)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q) error: %v", tt.name, s, err)
+		}
+		if !bytes.Equal(got.InfoHash, torrent.InfoHash) {
+			t.Errorf("%s: info hash = %x, want %x", tt.name, got.InfoHash, torrent.InfoHash)
+		}
+		got.InfoHash = nil
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%s: Parse(%q) = %+v, want %+v", tt.name, s, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+// Bounds of the piece length Create chooses.
+const (
+	minAutoPieceLength = 1 << 14
+	maxAutoPieceLength = 1 << 24
+	// targetPieces is the number of pieces Create aims for.
+	targetPieces = 1500
+)
+
+// CreateOptions configures Create.
+type CreateOptions struct {
+	// PieceLength must be a power of two. It is chosen from the total size
+	// if it is zero.
+	PieceLength int
+	// AnnounceList holds tiers of tracker URLs. The first URL becomes the
+	// "announce" key, and the whole list the "announce-list" key if it
+	// holds more than one URL.
+	AnnounceList [][]string
+	WebSeeds     []string
+	Comment      string
+	CreatedBy    string
+	// CreationDate is left out if it is zero.
+	CreationDate time.Time
+	Private      bool
+	// Source is stored in the info dictionary, which gives the torrent a
+	// different info hash for every source.
+	Source string
+	// Workers is the number of pieces hashed in parallel. It defaults to
+	// the number of CPUs.
+	Workers int
+}
+
+// sourceFile is a local file holding part of the data of a torrent.
+type sourceFile struct {
+	path string
+	// elems is the path relative to the torrent's directory.
+	elems  []string
+	length int64
+	offset int64
+}
+
+// Create hashes the file or directory tree at path and returns the
+// bencoded metainfo. A directory becomes a multi-file torrent holding its
+// regular files in lexical order.
+func Create(path string, opts CreateOptions) ([]byte, error) {
+	path = filepath.Clean(path)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		name = filepath.Base(abs)
+	}
+	if !validPathElem(name) {
+		return nil, fmt.Errorf("invalid torrent name %q", name)
+	}
+
+	var files []sourceFile
+	if stat.IsDir() {
+		files, err = listFiles(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = []sourceFile{{path: path, elems: []string{name}, length: stat.Size()}}
+	}
+	var total int64
+	for _, f := range files {
+		total += f.length
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%s holds no data", path)
+	}
+
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = autoPieceLength(total)
+	}
+	if pieceLength <= 0 || pieceLength&(pieceLength-1) != 0 {
+		return nil, fmt.Errorf("piece length %d is not a power of two", pieceLength)
+	}
+	pieces, err := hashPieces(files, total, pieceLength, opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+
+	info := infoDict{
+		Name:        name,
+		PieceLength: pieceLength,
+		Pieces:      pieces,
+		Source:      opts.Source,
+	}
+	if stat.IsDir() {
+		for _, f := range files {
+			info.Files = append(info.Files, fileDict{Length: f.length, Path: f.elems})
+		}
+	} else {
+		info.Length = &total
+	}
+	if opts.Private {
+		info.Private = 1
+	}
+	encodedInfo, err := bencode.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := metainfoDict{
+		Comment:   opts.Comment,
+		CreatedBy: opts.CreatedBy,
+		Info:      encodedInfo,
+	}
+	var urls []string
+	var tiers [][]string
+	for _, tier := range opts.AnnounceList {
+		if len(tier) > 0 {
+			urls = append(urls, tier...)
+			tiers = append(tiers, tier)
+		}
+	}
+	if len(urls) > 0 {
+		dict.Announce = urls[0]
+	}
+	if len(urls) > 1 {
+		dict.AnnounceList = tiers
+	}
+	if len(opts.WebSeeds) > 0 {
+		dict.URLList = opts.WebSeeds
+	}
+	if !opts.CreationDate.IsZero() {
+		dict.CreationDate = opts.CreationDate.Unix()
+	}
+	return bencode.Marshal(dict)
+}
+
+// listFiles returns the regular files under dir in lexical order.
+func listFiles(dir string) ([]sourceFile, error) {
+	var files []sourceFile
+	var offset int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		elems := strings.Split(filepath.ToSlash(rel), "/")
+		for _, e := range elems {
+			if !validPathElem(e) {
+				return fmt.Errorf("invalid file name %q", rel)
+			}
+		}
+		files = append(files, sourceFile{path: path, elems: elems, length: info.Size(), offset: offset})
+		offset += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s holds no files", dir)
+	}
+	return files, nil
+}
+
+// autoPieceLength returns the smallest power of two that splits total
+// bytes into at most targetPieces pieces, within the usual bounds.
+func autoPieceLength(total int64) int {
+	pieceLength := minAutoPieceLength
+	for pieceLength < maxAutoPieceLength && total/int64(pieceLength) > targetPieces {
+		pieceLength *= 2
+	}
+	return pieceLength
+}
+
+// hashPieces returns the concatenated SHA-1 hashes of the pieces of the
+// files laid out back to back, hashing with the given number of workers.
+func hashPieces(files []sourceFile, total int64, pieceLength, workers int) ([]byte, error) {
+	numPieces := int((total + int64(pieceLength) - 1) / int64(pieceLength))
+	hashes := make([]byte, numPieces*sha1.Size)
+	err := forEachPiece(numPieces, pieceLength, workers, func(i int, buf []byte) error {
+		start := int64(i) * int64(pieceLength)
+		data := buf[:min(int64(pieceLength), total-start)]
+		if err := readFiles(files, start, data); err != nil {
+			return err
+		}
+		sum := sha1.Sum(data)
+		copy(hashes[i*sha1.Size:], sum[:])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// forEachPiece calls fn for every piece index below numPieces from the
+// given number of workers, runtime.NumCPU() if zero. Each worker passes fn
+// its own buffer of pieceLength bytes. It returns the first error fn
+// returned, after which no more pieces are started.
+func forEachPiece(numPieces, pieceLength, workers int, fn func(i int, buf []byte) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	indexes := make(chan int)
+	errs := make(chan error, workers)
+	done := make(chan struct{})
+	stop := sync.OnceFunc(func() { close(done) })
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, pieceLength)
+			for i := range indexes {
+				if err := fn(i, buf); err != nil {
+					errs <- err
+					stop()
+					return
+				}
+			}
+		}()
+	}
+send:
+	for i := range numPieces {
+		select {
+		case indexes <- i:
+		case <-done:
+			break send
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// readFiles fills data with the bytes at offset of the files laid out back
+// to back. Empty files are never opened, so they need not exist.
+func readFiles(files []sourceFile, offset int64, data []byte) error {
+	end := offset + int64(len(data))
+	for _, f := range files {
+		if f.length == 0 || f.offset >= end || f.offset+f.length <= offset {
+			continue
+		}
+		from := max(offset, f.offset)
+		to := min(end, f.offset+f.length)
+		if err := readFileAt(f.path, data[from-offset:to-offset], from-f.offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFileAt(path string, buf []byte, off int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.ReadAt(buf, off); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("%s: file shrank while hashing", path)
+		}
+		return err
+	}
+	return nil
+}
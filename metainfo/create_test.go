@@ -0,0 +1,160 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTree describes the files of a multi-file torrent in lexical order.
+// With 16 KiB pieces, piece 0 spans a and b/c, and piece 2 spans b/c, the
+// empty b/empty and d.
+var testTree = []struct {
+	path   string
+	length int
+}{
+	{"a", 5000},
+	{"b/c", 30000},
+	{"b/empty", 0},
+	{"d", 100},
+}
+
+// writeTestTree writes testTree under a new directory called name and
+// returns the directory and the concatenated data of its files.
+func writeTestTree(t *testing.T, name string) (string, []byte) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	var all []byte
+	for _, f := range testTree {
+		data := make([]byte, f.length)
+		for i := range data {
+			data[i] = byte(len(all) + i*7)
+		}
+		path := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, data...)
+	}
+	return dir, all
+}
+
+// pieceHashes returns the hex encoded SHA-1 hashes of data split into
+// pieces of pieceLength bytes.
+func pieceHashes(data []byte, pieceLength int) []string {
+	var hashes []string
+	for start := 0; start < len(data); start += pieceLength {
+		sum := sha1.Sum(data[start:min(start+pieceLength, len(data))])
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	return hashes
+}
+
+func TestCreateMultiFile(t *testing.T) {
+	dir, data := writeTestTree(t, "tree")
+	created := time.Unix(1700000000, 0)
+	opts := CreateOptions{
+		PieceLength:  1 << 14,
+		AnnounceList: [][]string{{"http://a/announce"}, {"http://b/announce", "http://c/announce"}},
+		WebSeeds:     []string{"http://seed/"},
+		Comment:      "comment",
+		CreatedBy:    "test",
+		CreationDate: created,
+		Private:      true,
+		Source:       "src",
+		Workers:      3,
+	}
+	b, err := Create(dir, opts)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	info, err := FromBytes(b)
+	if err != nil {
+		t.Fatalf("FromBytes() error: %v", err)
+	}
+
+	if info.Name != "tree" || !info.MultiFile || info.FileLength != int64(len(data)) || info.PieceLength != opts.PieceLength {
+		t.Errorf("name %q, multi-file %v, length %d, piece length %d", info.Name, info.MultiFile, info.FileLength, info.PieceLength)
+	}
+	var offset int64
+	var want []File
+	for _, f := range testTree {
+		want = append(want, File{Path: strings.Split(f.path, "/"), Length: int64(f.length), Offset: offset})
+		offset += int64(f.length)
+	}
+	if !reflect.DeepEqual(info.Files, want) {
+		t.Errorf("files = %+v, want %+v", info.Files, want)
+	}
+	if got := pieceHashes(data, opts.PieceLength); !reflect.DeepEqual(info.PieceHashes, got) {
+		t.Errorf("piece hashes = %v, want %v", info.PieceHashes, got)
+	}
+	if got := info.FilesInPiece(0); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("FilesInPiece(0) = %v, want [0 1]", got)
+	}
+
+	if info.TrackerURL != "http://a/announce" || !reflect.DeepEqual(info.AnnounceList, opts.AnnounceList) {
+		t.Errorf("trackers = %q, %q", info.TrackerURL, info.AnnounceList)
+	}
+	if !reflect.DeepEqual(info.WebSeeds, opts.WebSeeds) || info.Comment != opts.Comment || info.CreatedBy != opts.CreatedBy ||
+		!info.CreationDate.Equal(created) || !info.Private || info.Source != opts.Source {
+		t.Errorf("metadata = %+v", info)
+	}
+
+	// hashing is deterministic, whatever the number of workers
+	opts.Workers = 1
+	again, err := Create(dir, opts)
+	if err != nil || string(again) != string(b) {
+		t.Errorf("Create() with one worker differs: %v", err)
+	}
+}
+
+func TestCreateSingleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	data := make([]byte, 40000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Create(path, CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	info, err := FromBytes(b)
+	if err != nil {
+		t.Fatalf("FromBytes() error: %v", err)
+	}
+	if info.Name != "file.bin" || info.MultiFile || info.FileLength != 40000 || info.TrackerURL != "~" {
+		t.Errorf("name %q, multi-file %v, length %d, tracker %q", info.Name, info.MultiFile, info.FileLength, info.TrackerURL)
+	}
+	if info.PieceLength != minAutoPieceLength {
+		t.Errorf("piece length = %d, want %d", info.PieceLength, minAutoPieceLength)
+	}
+	if got := pieceHashes(data, info.PieceLength); !reflect.DeepEqual(info.PieceHashes, got) {
+		t.Errorf("piece hashes = %v, want %v", info.PieceHashes, got)
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	dir, _ := writeTestTree(t, "tree")
+	if _, err := Create(dir, CreateOptions{PieceLength: 3000}); err == nil {
+		t.Error("Create() with a piece length that is not a power of two succeeded")
+	}
+	empty := t.TempDir()
+	os.WriteFile(filepath.Join(empty, "zero"), nil, 0o644)
+	if _, err := Create(empty, CreateOptions{}); err == nil {
+		t.Error("Create() of a directory without data succeeded")
+	}
+	if _, err := Create(filepath.Join(dir, "missing"), CreateOptions{}); err == nil {
+		t.Error("Create() of a missing file succeeded")
+	}
+}
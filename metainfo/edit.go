@@ -0,0 +1,105 @@
+package metainfo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+// EditOptions lists the changes Edit makes. Nil fields are left as they
+// are.
+type EditOptions struct {
+	// AnnounceList replaces the tiers of tracker URLs, as in
+	// CreateOptions. An empty list removes every tracker.
+	AnnounceList *[][]string
+	// WebSeeds replaces the HTTP seed URLs.
+	WebSeeds *[]string
+	// Comment is removed if empty.
+	Comment *string
+	// CreationDate is removed if zero.
+	CreationDate *time.Time
+	// Private and Source are stored in the info dictionary, so changing
+	// them changes the info hash.
+	Private *bool
+	// Source is removed if empty.
+	Source *string
+}
+
+// Edit applies opts to the metainfo file data and returns the new
+// content. The keys it does not change, including unknown ones, are
+// copied byte for byte, and so is the info dictionary unless Private or
+// Source is set.
+func Edit(data []byte, opts EditOptions) ([]byte, error) {
+	fields, err := bencode.RawDict(data)
+	if err != nil {
+		return nil, err
+	}
+	if fields["info"] == nil {
+		return nil, fmt.Errorf("metainfo has no info dictionary")
+	}
+	dict := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		dict[k] = v
+	}
+
+	if opts.AnnounceList != nil {
+		delete(dict, "announce")
+		delete(dict, "announce-list")
+		var urls []string
+		var tiers [][]string
+		for _, tier := range *opts.AnnounceList {
+			if len(tier) > 0 {
+				urls = append(urls, tier...)
+				tiers = append(tiers, tier)
+			}
+		}
+		if len(urls) > 0 {
+			dict["announce"] = urls[0]
+		}
+		if len(urls) > 1 {
+			dict["announce-list"] = tiers
+		}
+	}
+	if opts.WebSeeds != nil {
+		setOrDelete(dict, "url-list", *opts.WebSeeds, len(*opts.WebSeeds) > 0)
+	}
+	if opts.Comment != nil {
+		setOrDelete(dict, "comment", *opts.Comment, *opts.Comment != "")
+	}
+	if opts.CreationDate != nil {
+		setOrDelete(dict, "creation date", opts.CreationDate.Unix(), !opts.CreationDate.IsZero())
+	}
+
+	if opts.Private != nil || opts.Source != nil {
+		infoFields, err := bencode.RawDict(fields["info"])
+		if err != nil {
+			return nil, err
+		}
+		info := make(map[string]interface{}, len(infoFields))
+		for k, v := range infoFields {
+			info[k] = v
+		}
+		if opts.Private != nil {
+			setOrDelete(info, "private", 1, *opts.Private)
+		}
+		if opts.Source != nil {
+			setOrDelete(info, "source", *opts.Source, *opts.Source != "")
+		}
+		encoded, err := bencode.Encode(info)
+		if err != nil {
+			return nil, err
+		}
+		dict["info"] = bencode.RawMessage(encoded)
+	}
+	return bencode.Encode(dict)
+}
+
+// setOrDelete sets dict[key] to val if set, and removes key otherwise.
+func setOrDelete(dict map[string]interface{}, key string, val interface{}, set bool) {
+	if set {
+		dict[key] = val
+	} else {
+		delete(dict, key)
+	}
+}
@@ -0,0 +1,66 @@
+package metainfo
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+func TestEditKeepsInfoBytes(t *testing.T) {
+	// a non-canonical info dictionary with an unknown key, which must not
+	// be re-encoded
+	info := "d4:name1:x6:lengthi03e12:piece lengthi16384e" + testPieces + "1:zd1:b0:1:a0:ee"
+	data := []byte("d8:announce5:old/a7:comment3:old5:extrali1ee4:info" + info + "e")
+	before, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tiers := [][]string{{"http://a/announce"}, {"http://b/announce"}}
+	seeds := []string{"http://seed/"}
+	comment := ""
+	date := time.Unix(1700000000, 0)
+	out, err := Edit(data, EditOptions{AnnounceList: &tiers, WebSeeds: &seeds, Comment: &comment, CreationDate: &date})
+	if err != nil {
+		t.Fatalf("Edit() error: %v", err)
+	}
+	fields, err := bencode.RawDict(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["info"]) != info {
+		t.Errorf("info dictionary = %q, want %q", fields["info"], info)
+	}
+	if string(fields["extra"]) != "li1ee" {
+		t.Errorf("unknown key extra = %q, want it kept", fields["extra"])
+	}
+
+	after, err := FromBytes(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after.InfoHash, before.InfoHash) {
+		t.Errorf("info hash changed from %x to %x", before.InfoHash, after.InfoHash)
+	}
+	if after.TrackerURL != "http://a/announce" || !reflect.DeepEqual(after.AnnounceList, tiers) ||
+		!reflect.DeepEqual(after.WebSeeds, seeds) || after.Comment != "" || !after.CreationDate.Equal(date) {
+		t.Errorf("edited metainfo = %+v", after)
+	}
+
+	// private and source live in the info dictionary
+	private := true
+	out, err = Edit(data, EditOptions{Private: &private})
+	if err != nil {
+		t.Fatalf("Edit() error: %v", err)
+	}
+	after, err = FromBytes(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Private || bytes.Equal(after.InfoHash, before.InfoHash) {
+		t.Errorf("Edit() with Private: private %v, info hash %x", after.Private, after.InfoHash)
+	}
+}
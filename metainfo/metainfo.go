@@ -0,0 +1,313 @@
+// Package metainfo parses BitTorrent metainfo (.torrent) files.
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+// TorrentInfo holds the parts of a metainfo file needed to download it.
+type TorrentInfo struct {
+	// TrackerURL is the announce URL, or "~" if the metainfo has none.
+	TrackerURL string
+	// AnnounceList holds the tiers of tracker URLs of the "announce-list"
+	// extension, if present.
+	AnnounceList [][]string
+	// WebSeeds holds the HTTP seed URLs of the "url-list" key.
+	WebSeeds []string
+	// CreationDate is zero if the metainfo does not record it.
+	CreationDate time.Time
+	Comment      string
+	CreatedBy    string
+	// Private is set if the info dictionary restricts peers to those
+	// returned by the tracker.
+	Private bool
+	// Source is the source tag of the info dictionary, which private
+	// trackers use to give their torrents distinct info hashes.
+	Source string
+	Name   string
+	// FileLength is the total length of all files.
+	FileLength int64
+	// MultiFile is set if the info dictionary lists several files, which
+	// are saved in a directory called Name.
+	MultiFile bool
+	// Files lists the files of the torrent in the order their data is laid
+	// out. A single-file torrent has one file whose path is Name.
+	Files []File
+	// InfoHash is the SHA-1 hash of the bencoded info dictionary.
+	InfoHash    []byte
+	PieceLength int
+	// PieceHashes holds the hex encoded SHA-1 hash of every piece.
+	PieceHashes []string
+}
+
+// File is one file of a torrent.
+type File struct {
+	// Path is the path of the file relative to the torrent's directory,
+	// split into its components.
+	Path   []string
+	Length int64
+	// Offset is the position of the file's first byte in the torrent's
+	// data.
+	Offset int64
+}
+
+// metainfoDict is the encoding of a metainfo file. The info dictionary is
+// kept encoded, as its hash must be computed over its original bytes.
+type metainfoDict struct {
+	Announce string `bencode:"announce,omitempty"`
+	// AnnounceList and URLList are decoded loosely, as clients disagree on
+	// their shape.
+	AnnounceList interface{}        `bencode:"announce-list,omitempty"`
+	URLList      interface{}        `bencode:"url-list,omitempty"`
+	Comment      string             `bencode:"comment,omitempty"`
+	CreatedBy    string             `bencode:"created by,omitempty"`
+	CreationDate int64              `bencode:"creation date,omitempty"`
+	Info         bencode.RawMessage `bencode:"info,omitempty"`
+}
+
+// infoDict is the encoding of an info dictionary. Single-file torrents
+// have a length, multi-file ones a list of files.
+type infoDict struct {
+	Name        string     `bencode:"name"`
+	Length      *int64     `bencode:"length,omitempty"`
+	Files       []fileDict `bencode:"files,omitempty"`
+	PieceLength int        `bencode:"piece length"`
+	Pieces      []byte     `bencode:"pieces"`
+	Private     int        `bencode:"private,omitempty"`
+	Source      string     `bencode:"source,omitempty"`
+}
+
+type fileDict struct {
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+}
+
+// FromBytes parses the content of a .torrent file. The content may also be
+// a bare info dictionary, as received through the metadata extension. The
+// info hash is computed over the info dictionary exactly as it is encoded
+// in data.
+func FromBytes(data []byte) (*TorrentInfo, error) {
+	raw, err := bencode.NewDecoder(bytes.NewReader(data)).DecodeRaw()
+	if err != nil {
+		return nil, err
+	}
+	var dict metainfoDict
+	if err := bencode.Unmarshal(raw, &dict); err != nil {
+		return nil, fmt.Errorf("invalid metainfo: %w", err)
+	}
+	if dict.Info != nil {
+		raw = dict.Info
+	}
+	var info infoDict
+	if err := bencode.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("invalid info dictionary: %w", err)
+	}
+	sum := sha1.Sum(raw)
+
+	trackerUrl := dict.Announce
+	if trackerUrl == "" {
+		trackerUrl = "~"
+	}
+	name := info.Name
+	if name == "" {
+		name = "~"
+	} else if !validPathElem(name) {
+		return nil, fmt.Errorf("info dictionary has an invalid name")
+	}
+
+	var files []File
+	multiFile := info.Length == nil
+	var length int64
+	if multiFile {
+		files, err = parseFiles(info.Files)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			length += f.Length
+		}
+	} else {
+		length = *info.Length
+		if length < 0 {
+			return nil, fmt.Errorf("info dictionary has a negative length")
+		}
+		files = []File{{Path: []string{name}, Length: length}}
+	}
+	pieceLength := info.PieceLength
+	if pieceLength <= 0 {
+		return nil, fmt.Errorf("info dictionary has no valid piece length")
+	}
+	if len(info.Pieces)%sha1.Size != 0 {
+		return nil, fmt.Errorf("info dictionary has no valid pieces")
+	}
+	pieces := make([]string, 0, len(info.Pieces)/sha1.Size)
+	for i := 0; i < len(info.Pieces); i += sha1.Size {
+		pieces = append(pieces, hex.EncodeToString(info.Pieces[i:i+sha1.Size]))
+	}
+	if want := (length + int64(pieceLength) - 1) / int64(pieceLength); int64(len(pieces)) != want {
+		return nil, fmt.Errorf("info dictionary has %d pieces, want %d", len(pieces), want)
+	}
+
+	var creationDate time.Time
+	if dict.CreationDate != 0 {
+		creationDate = time.Unix(dict.CreationDate, 0).UTC()
+	}
+
+	return &TorrentInfo{
+		TrackerURL:   trackerUrl,
+		AnnounceList: parseAnnounceList(dict.AnnounceList),
+		WebSeeds:     parseWebSeeds(dict.URLList),
+		CreationDate: creationDate,
+		Comment:      dict.Comment,
+		CreatedBy:    dict.CreatedBy,
+		Private:      info.Private == 1,
+		Source:       info.Source,
+		Name:         name,
+		FileLength:   length,
+		MultiFile:    multiFile,
+		Files:        files,
+		InfoHash:     sum[:],
+		PieceLength:  pieceLength,
+		PieceHashes:  pieces,
+	}, nil
+}
+
+// parseAnnounceList parses the tiers of an "announce-list". Entries that
+// are not strings and empty tiers are dropped.
+func parseAnnounceList(val interface{}) [][]string {
+	list, _ := val.([]interface{})
+	var tiers [][]string
+	for _, v := range list {
+		entries, _ := v.([]interface{})
+		var tier []string
+		for _, e := range entries {
+			if url, ok := str(e); ok && url != "" {
+				tier = append(tier, url)
+			}
+		}
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// parseWebSeeds parses a "url-list", which is either a single URL or a
+// list of them.
+func parseWebSeeds(val interface{}) []string {
+	switch v := val.(type) {
+	case []byte:
+		if len(v) > 0 {
+			return []string{string(v)}
+		}
+	case []interface{}:
+		var urls []string
+		for _, e := range v {
+			if url, ok := str(e); ok && url != "" {
+				urls = append(urls, url)
+			}
+		}
+		return urls
+	}
+	return nil
+}
+
+// parseFiles checks the "files" list of a multi-file info dictionary.
+func parseFiles(list []fileDict) ([]File, error) {
+	if len(list) == 0 {
+		return nil, fmt.Errorf("info dictionary has no length or files")
+	}
+	files := make([]File, 0, len(list))
+	var offset int64
+	for i, f := range list {
+		if f.Length < 0 {
+			return nil, fmt.Errorf("file %d has no valid length", i)
+		}
+		if len(f.Path) == 0 {
+			return nil, fmt.Errorf("file %d has no path", i)
+		}
+		for _, elem := range f.Path {
+			if !validPathElem(elem) {
+				return nil, fmt.Errorf("file %d has an invalid path", i)
+			}
+		}
+		files = append(files, File{Path: f.Path, Length: f.Length, Offset: offset})
+		offset += f.Length
+	}
+	return files, nil
+}
+
+// str returns the decoded byte string val as a string.
+func str(val interface{}) (string, bool) {
+	b, ok := val.([]byte)
+	return string(b), ok
+}
+
+// validPathElem reports whether elem may be used as a file or directory
+// name, so a torrent cannot write outside of its directory.
+func validPathElem(elem string) bool {
+	return elem != "" && elem != "." && elem != ".." && !strings.ContainsAny(elem, "/\\\x00")
+}
+
+// FromFile reads and parses the named .torrent file.
+func FromFile(filename string) (*TorrentInfo, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromBytes(data)
+}
+
+// NumPieces returns the number of pieces in the torrent.
+func (t *TorrentInfo) NumPieces() int {
+	return len(t.PieceHashes)
+}
+
+// PieceSize returns the length of piece index. Every piece but the last is
+// PieceLength bytes long.
+func (t *TorrentInfo) PieceSize(index int) int {
+	if index == t.NumPieces()-1 {
+		return int(t.FileLength - t.PieceOffset(index))
+	}
+	return t.PieceLength
+}
+
+// PieceOffset returns the position of the first byte of piece index in the
+// torrent's data.
+func (t *TorrentInfo) PieceOffset(index int) int64 {
+	return int64(index) * int64(t.PieceLength)
+}
+
+// FilesInPiece returns the indexes of the files that piece index overlaps.
+func (t *TorrentInfo) FilesInPiece(index int) []int {
+	start := t.PieceOffset(index)
+	end := start + int64(t.PieceSize(index))
+	var files []int
+	for i, f := range t.Files {
+		if f.Offset < end && f.Offset+f.Length > start {
+			files = append(files, i)
+		}
+	}
+	return files
+}
+
+// VerifyPiece checks data against the hash of piece index.
+func (t *TorrentInfo) VerifyPiece(index int, data []byte) error {
+	if index < 0 || index >= t.NumPieces() {
+		return fmt.Errorf("piece index %d out of range", index)
+	}
+	sum := sha1.Sum(data)
+	if hex.EncodeToString(sum[:]) != t.PieceHashes[index] {
+		return fmt.Errorf("piece %d failed hash check", index)
+	}
+	return nil
+}
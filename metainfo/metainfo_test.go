@@ -0,0 +1,65 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+var testPieces = "6:pieces20:" + strings.Repeat("a", 20)
+
+// bstr bencodes s as a byte string.
+func bstr(s string) string {
+	return fmt.Sprintf("%d:%s", len(s), s)
+}
+
+func TestFromBytesInvalidPaths(t *testing.T) {
+	for _, name := range []string{"../x", "..", ".", "a/b", `a\b`, "a\x00"} {
+		single := "d4:infod6:lengthi3e4:name" + bstr(name) + "12:piece lengthi16384e" + testPieces + "ee"
+		if _, err := FromBytes([]byte(single)); err == nil {
+			t.Errorf("torrent named %q accepted", name)
+		}
+		multi := "d4:infod5:filesld6:lengthi3e4:pathl1:d" + bstr(name) + "eee4:name1:x12:piece lengthi16384e" + testPieces + "ee"
+		if _, err := FromBytes([]byte(multi)); err == nil {
+			t.Errorf("file path d/%q accepted", name)
+		}
+	}
+
+	info, err := FromBytes([]byte("d4:infod6:lengthi3e4:name5:..x..12:piece lengthi16384e" + testPieces + "ee"))
+	if err != nil || info.Name != "..x.." {
+		t.Errorf("FromBytes() of a torrent named \"..x..\" = %v, %v", info, err)
+	}
+}
+
+func TestInfoHashOverOriginalBytes(t *testing.T) {
+	// keys out of order, and an integer with a leading zero: re-encoding
+	// this dictionary would change its bytes
+	info := "d4:name1:x6:lengthi03e12:piece lengthi16384e" + testPieces + "7:privatei0e1:zd1:b0:1:a0:ee"
+	got, err := FromBytes([]byte("d8:announce3:url4:info" + info + "e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha1.Sum([]byte(info))
+	if !bytes.Equal(got.InfoHash, want[:]) {
+		t.Errorf("InfoHash = %x, want %x", got.InfoHash, want)
+	}
+
+	decoded, err := bencode.Decode([]byte(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := bencode.Encode(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha1.Sum(canonical); bytes.Equal(got.InfoHash, sum[:]) {
+		t.Error("InfoHash is the hash of the re-encoded dictionary")
+	}
+	if got.Name != "x" || got.FileLength != 3 {
+		t.Errorf("name %q, length %d", got.Name, got.FileLength)
+	}
+}
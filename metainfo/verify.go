@@ -0,0 +1,87 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// PieceState is the result of checking a piece against data on disk.
+type PieceState int
+
+const (
+	// PieceGood means the data matches the piece hash.
+	PieceGood PieceState = iota
+	// PieceBad means the data does not match the piece hash.
+	PieceBad
+	// PieceMissing means a file the piece spans is missing or too short.
+	PieceMissing
+)
+
+func (s PieceState) String() string {
+	switch s {
+	case PieceGood:
+		return "good"
+	case PieceBad:
+		return "bad"
+	case PieceMissing:
+		return "missing"
+	default:
+		return "unknown"
+	}
+}
+
+// Verify checks the data at path against the piece hashes, hashing pieces
+// with the given number of workers, or one per CPU if zero. path is the
+// file of a single-file torrent, or the directory holding the files of a
+// multi-file torrent. An error is only returned if data could not be read
+// for another reason than a missing or short file.
+func (t *TorrentInfo) Verify(path string, workers int) ([]PieceState, error) {
+	files := make([]sourceFile, len(t.Files))
+	// available is the number of bytes of each file on disk
+	available := make([]int64, len(t.Files))
+	for i, f := range t.Files {
+		name := path
+		if t.MultiFile {
+			name = filepath.Join(path, filepath.Join(f.Path...))
+		}
+		files[i] = sourceFile{path: name, elems: f.Path, length: f.Length, offset: f.Offset}
+		info, err := os.Stat(name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, err
+		case info.Mode().IsRegular():
+			available[i] = min(info.Size(), f.Length)
+		}
+	}
+
+	states := make([]PieceState, t.NumPieces())
+	err := forEachPiece(t.NumPieces(), t.PieceLength, workers, func(i int, buf []byte) error {
+		start := t.PieceOffset(i)
+		end := start + int64(t.PieceSize(i))
+		for _, fi := range t.FilesInPiece(i) {
+			f := files[fi]
+			if f.offset+available[fi] < min(end, f.offset+f.length) {
+				states[i] = PieceMissing
+				return nil
+			}
+		}
+		data := buf[:end-start]
+		if err := readFiles(files, start, data); err != nil {
+			return err
+		}
+		sum := sha1.Sum(data)
+		if hex.EncodeToString(sum[:]) != t.PieceHashes[i] {
+			states[i] = PieceBad
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
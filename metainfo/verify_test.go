@@ -0,0 +1,162 @@
+package metainfo
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+func TestVerify(t *testing.T) {
+	const (
+		good    = PieceGood
+		bad     = PieceBad
+		missing = PieceMissing
+	)
+	tests := []struct {
+		name string
+		// change damages the tree written by writeTestTree.
+		change func(t *testing.T, dir string)
+		want   []PieceState
+	}{
+		{"intact", func(t *testing.T, dir string) {}, []PieceState{good, good, good}},
+		{"empty file missing", func(t *testing.T, dir string) { remove(t, dir, "b/empty") }, []PieceState{good, good, good}},
+		{"byte flipped in the first file", func(t *testing.T, dir string) { flip(t, dir, "a", 10) }, []PieceState{bad, good, good}},
+		{"byte flipped across pieces", func(t *testing.T, dir string) { flip(t, dir, "b/c", 20000) }, []PieceState{good, bad, good}},
+		{"first file missing", func(t *testing.T, dir string) { remove(t, dir, "a") }, []PieceState{missing, good, good}},
+		{"last file truncated", func(t *testing.T, dir string) { truncate(t, dir, "d", 99) }, []PieceState{good, good, missing}},
+		{"spanning file truncated", func(t *testing.T, dir string) { truncate(t, dir, "b/c", 10000) }, []PieceState{missing, missing, missing}},
+		{"file too long", func(t *testing.T, dir string) { appendTo(t, dir, "d", "extra") }, []PieceState{good, good, good}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := writeTestTree(t, "tree")
+			b, err := Create(dir, CreateOptions{PieceLength: 1 << 14})
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := FromBytes(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t, dir)
+			for _, workers := range []int{0, 1} {
+				got, err := info.Verify(dir, workers)
+				if err != nil {
+					t.Fatalf("Verify() error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Verify() with %d workers = %v, want %v", workers, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyPieceLengthNotPowerOfTwo(t *testing.T) {
+	dir, data := writeTestTree(t, "tree")
+	const pieceLength = 10000
+	var pieces strings.Builder
+	for _, h := range pieceHashes(data, pieceLength) {
+		sum, _ := hex.DecodeString(h)
+		pieces.Write(sum)
+	}
+	var files []interface{}
+	for _, f := range testTree {
+		files = append(files, map[string]interface{}{"length": f.length, "path": strings.Split(f.path, "/")})
+	}
+	b, err := bencode.Marshal(map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         "tree",
+			"piece length": pieceLength,
+			"pieces":       pieces.String(),
+			"files":        files,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := FromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 35100 bytes make four pieces; the last one spans b/c, b/empty and d
+	want := []PieceState{PieceGood, PieceGood, PieceGood, PieceGood}
+	if got, err := info.Verify(dir, 2); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify() = %v, %v, want %v", got, err, want)
+	}
+	flip(t, dir, "b/c", 14999)
+	truncate(t, dir, "d", 0)
+	want = []PieceState{PieceGood, PieceBad, PieceGood, PieceMissing}
+	if got, err := info.Verify(dir, 2); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify() of damaged data = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestVerifySingleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 20000)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Create(path, CreateOptions{PieceLength: 1 << 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := FromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PieceState{PieceGood, PieceGood}
+	if got, err := info.Verify(path, 0); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify() = %v, %v, want %v", got, err, want)
+	}
+	os.Remove(path)
+	want = []PieceState{PieceMissing, PieceMissing}
+	if got, err := info.Verify(path, 0); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify() of a missing file = %v, %v, want %v", got, err, want)
+	}
+}
+
+func remove(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.Remove(filepath.Join(dir, name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func flip(t *testing.T, dir, name string, offset int) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[offset] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func truncate(t *testing.T, dir, name string, size int64) {
+	t.Helper()
+	if err := os.Truncate(filepath.Join(dir, name), size); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendTo(t *testing.T, dir, name, data string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package peerwire implements the BitTorrent peer wire protocol: the
+// handshake, length-prefixed messages and the extension protocol used to
+// fetch metadata for magnet links.
+package peerwire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+)
+
+// ProtocolName is the protocol string sent in every handshake.
+const ProtocolName = "BitTorrent protocol"
+
+// handshakeTimeout bounds dialing a peer and exchanging handshakes.
+const handshakeTimeout = 30 * time.Second
+
+// handshakeLength is the size of a handshake: the protocol string and its
+// length prefix, 8 reserved bytes, the info hash and the peer ID.
+const handshakeLength = 1 + 19 + 8 + 20 + 20
+
+// Conn is a connection to a peer that completed the handshake.
+type Conn struct {
+	net.Conn
+	// InfoHash is the info hash of the torrent the connection is for.
+	InfoHash []byte
+	// PeerID is the 20-byte ID the remote peer sent in its handshake.
+	PeerID []byte
+	// Reserved holds the reserved bytes of the remote peer's handshake.
+	Reserved []byte
+
+	ctx          context.Context
+	uploadLimits []*ratelimit.Limiter
+	pieceSent    func(n int)
+}
+
+// SetUploadLimits makes WriteMessage wait for the given limiters before
+// sending piece data. It must not be called while messages are written.
+func (c *Conn) SetUploadLimits(limiters ...*ratelimit.Limiter) {
+	c.uploadLimits = limiters
+}
+
+// SetPieceSent sets a function WriteMessage calls with the size of the
+// data of every piece message it sent. It must not be called while
+// messages are written.
+func (c *Conn) SetPieceSent(fn func(n int)) {
+	c.pieceSent = fn
+}
+
+// SetTimeout makes reads and writes fail if they do not complete within d.
+// A zero d removes the timeout. The deadline of the context the connection
+// is bound to always applies.
+func (c *Conn) SetTimeout(d time.Duration) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	var deadline time.Time
+	if d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	if ctxDeadline, ok := c.ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	return c.Conn.SetDeadline(deadline)
+}
+
+// ctxConn is a connection whose blocking reads and writes are interrupted
+// once the context it was opened with is done.
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// bindConnToContext applies ctx's deadline to conn and unblocks any pending
+// read or write on conn when ctx is cancelled.
+func bindConnToContext(ctx context.Context, conn net.Conn) net.Conn {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	return &ctxConn{Conn: conn, stop: stop}
+}
+
+// Dial connects to the peer at address and performs the handshake for
+// infoHash. If reserved is not empty it is sent as the reserved handshake
+// bytes and the peer must support every bit set in it. The connection stays
+// bound to ctx: cancelling ctx interrupts any read or write on it.
+func Dial(ctx context.Context, address string, peerID string, infoHash []byte, reserved []byte) (*Conn, error) {
+	d := net.Dialer{Timeout: handshakeTimeout}
+	rawConn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn := bindConnToContext(ctx, rawConn)
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	c, err := handshake(conn, peerID, infoHash, reserved)
+	if err == nil {
+		c.ctx = ctx
+		err = c.SetTimeout(0)
+	}
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// Accept performs the handshake on an incoming connection. The remote peer
+// speaks first; known is called with the info hash it asked for and must
+// report whether we serve that torrent. Like Dial, the connection stays
+// bound to ctx.
+func Accept(ctx context.Context, rawConn net.Conn, peerID string, known func(infoHash []byte) bool) (*Conn, error) {
+	conn := bindConnToContext(ctx, rawConn)
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	handshakebuffer, err := readHandshake(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	infoHash := handshakebuffer[1+19+8:][:20]
+	if !known(infoHash) {
+		conn.Close()
+		return nil, fmt.Errorf("peer asked for unknown info hash %x", infoHash)
+	}
+	if _, err := conn.Write(buildHandshake(peerID, infoHash, nil)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Conn{
+		Conn:     conn,
+		InfoHash: infoHash,
+		PeerID:   handshakebuffer[1+19+8+20:],
+		Reserved: handshakebuffer[1+19:][:8],
+		ctx:      ctx,
+	}
+	if err := c.SetTimeout(0); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func buildHandshake(peerID string, infoHash []byte, extension []byte) []byte {
+	pstrlen := byte(len(ProtocolName))
+	pstr := []byte(ProtocolName)
+	reserved := make([]byte, 8) // Eight zeros
+	if len(extension) != 0 {
+		reserved = extension
+	}
+	handshake := append([]byte{pstrlen}, pstr...)
+	handshake = append(handshake, reserved...)
+	handshake = append(handshake, infoHash...)
+	handshake = append(handshake, []byte(peerID)...)
+	return handshake
+}
+
+func readHandshake(conn net.Conn) ([]byte, error) {
+	handshakebuffer := make([]byte, handshakeLength)
+	if _, err := io.ReadFull(conn, handshakebuffer); err != nil {
+		return nil, err
+	}
+	if handshakebuffer[0] != byte(len(ProtocolName)) || string(handshakebuffer[1:1+19]) != ProtocolName {
+		return nil, fmt.Errorf("unexpected protocol in handshake")
+	}
+	return handshakebuffer, nil
+}
+
+func handshake(conn net.Conn, peerID string, infoHash []byte, extension []byte) (*Conn, error) {
+	if _, err := conn.Write(buildHandshake(peerID, infoHash, extension)); err != nil {
+		return nil, err
+	}
+
+	handshakebuffer, err := readHandshake(conn)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(handshakebuffer[1+19+8:][:20], infoHash) {
+		return nil, fmt.Errorf("peer handshake has a different info hash")
+	}
+
+	retExtension := handshakebuffer[1+19:][:8]
+	if len(extension) != 0 {
+		ok := checkExtSupport(extension, retExtension)
+		if !ok {
+			return nil, fmt.Errorf("extension not supported %v, received %v", extension, retExtension)
+		}
+	}
+
+	return &Conn{
+		Conn:     conn,
+		InfoHash: infoHash,
+		PeerID:   handshakebuffer[1+19+8+20:],
+		Reserved: retExtension,
+	}, nil
+}
+
+func checkExtSupport(sent, rcv []byte) bool {
+	if len(sent) != len(rcv) {
+		return false
+	}
+
+	for i := range sent {
+		check := sent[i] & rcv[i]
+		if check != sent[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package peerwire
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+// MetadataExtensionID is the ID we ask peers to use for ut_metadata
+// messages they send to us.
+const MetadataExtensionID = 69
+
+// ExtensionReserved returns reserved handshake bytes that advertise support
+// for the extension protocol (BEP 10).
+func ExtensionReserved() []byte {
+	a := make([]byte, 8)
+	a[5] = 16
+	return a
+}
+
+// ExtendedHandshake is the dictionary exchanged in the extension protocol
+// handshake.
+type ExtendedHandshake struct {
+	// M maps the names of the extensions a peer supports to the message
+	// IDs it wants them sent with.
+	M            map[string]int `bencode:"m"`
+	MetadataSize int            `bencode:"metadata_size,omitempty"`
+}
+
+// metadataMsg is the dictionary starting a ut_metadata message.
+type metadataMsg struct {
+	MsgType   int `bencode:"msg_type"`
+	Piece     int `bencode:"piece"`
+	TotalSize int `bencode:"total_size,omitempty"`
+}
+
+// ut_metadata message types
+const (
+	metadataRequest = 0
+	metadataData    = 1
+	metadataReject  = 2
+)
+
+// newPayloadDecoder returns a decoder for the dictionary of an extension
+// message, which is never deeply nested.
+func newPayloadDecoder(payload []byte) *bencode.Decoder {
+	d := bencode.NewDecoder(bytes.NewReader(payload))
+	d.SetMaxDepth(8)
+	return d
+}
+
+// ExtensionHandshake sends our extension handshake and returns the
+// handshake of the peer.
+func (c *Conn) ExtensionHandshake() (*ExtendedHandshake, error) {
+	payload, err := bencode.Marshal(ExtendedHandshake{
+		M: map[string]int{"ut_metadata": MetadataExtensionID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// extension message id 0 is the handshake
+	if err := c.WriteMessage(MsgExtended, append([]byte{0}, payload...)); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.Await(MsgExtended)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Payload) == 0 || msg.Payload[0] != 0 {
+		return nil, fmt.Errorf("expected extension handshake")
+	}
+	var handshake ExtendedHandshake
+	if err := newPayloadDecoder(msg.Payload[1:]).DecodeInto(&handshake); err != nil {
+		return nil, fmt.Errorf("invalid extension handshake: %w", err)
+	}
+	return &handshake, nil
+}
+
+// PeerMetadataExtensionID returns the ID the peer assigned to ut_metadata in
+// its extension handshake.
+func PeerMetadataExtensionID(handshake *ExtendedHandshake) (byte, error) {
+	id, ok := handshake.M["ut_metadata"]
+	if !ok || id <= 0 || id > 255 {
+		return 0, fmt.Errorf("peer does not support ut_metadata")
+	}
+	return byte(id), nil
+}
+
+// RequestMetadata requests the first metadata piece through the ut_metadata
+// extension, using the extension ID the peer assigned, and returns the
+// encoded info dictionary as sent by the peer.
+func (c *Conn) RequestMetadata(extID byte) ([]byte, error) {
+	p, err := bencode.Marshal(metadataMsg{MsgType: metadataRequest, Piece: 0})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.WriteMessage(MsgExtended, append([]byte{extID}, p...)); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.Await(MsgExtended)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Payload) == 0 || msg.Payload[0] != MetadataExtensionID {
+		return nil, fmt.Errorf("expected ut_metadata message")
+	}
+
+	// the payload is a dictionary followed by the raw metadata piece
+	d := newPayloadDecoder(msg.Payload[1:])
+	var reply metadataMsg
+	if err := d.DecodeInto(&reply); err != nil {
+		return nil, fmt.Errorf("invalid ut_metadata message: %w", err)
+	}
+	switch reply.MsgType {
+	case metadataData:
+	case metadataReject:
+		return nil, fmt.Errorf("peer rejected the metadata request")
+	default:
+		return nil, fmt.Errorf("unexpected ut_metadata message type %d", reply.MsgType)
+	}
+
+	return msg.Payload[1+d.InputOffset():], nil
+}
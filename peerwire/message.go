@@ -0,0 +1,161 @@
+package peerwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+)
+
+// MessageID identifies the type of a peer wire message.
+type MessageID byte
+
+// Message IDs defined by BEP 3 and BEP 10.
+const (
+	MsgChoke         MessageID = 0
+	MsgUnchoke       MessageID = 1
+	MsgInterested    MessageID = 2
+	MsgNotInterested MessageID = 3
+	MsgHave          MessageID = 4
+	MsgBitfield      MessageID = 5
+	MsgRequest       MessageID = 6
+	MsgPiece         MessageID = 7
+	MsgCancel        MessageID = 8
+	MsgExtended      MessageID = 20
+)
+
+// maxMessageLength bounds the size of a single incoming message. It leaves
+// room for the bitfield of a torrent with millions of pieces.
+const maxMessageLength = 1 << 22
+
+// ErrChoked is returned when the peer chokes us while we wait for a reply.
+var ErrChoked = errors.New("peer choked us")
+
+// Message is a single peer wire message.
+type Message struct {
+	ID      MessageID
+	Payload []byte
+}
+
+// ReadMessage reads the next message from the peer. Keep-alive messages are
+// skipped.
+func (c *Conn) ReadMessage() (*Message, error) {
+	for {
+		lengthBuffer := make([]byte, 4)
+		if _, err := io.ReadFull(c, lengthBuffer); err != nil {
+			return nil, err
+		}
+		length := binary.BigEndian.Uint32(lengthBuffer)
+		if length == 0 {
+			continue
+		}
+		if length > maxMessageLength {
+			return nil, fmt.Errorf("message of %d bytes exceeds limit", length)
+		}
+
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(c, msg); err != nil {
+			return nil, err
+		}
+		return &Message{ID: MessageID(msg[0]), Payload: msg[1:]}, nil
+	}
+}
+
+// WriteMessage sends a message with the given ID and payload. Piece
+// messages are throttled by the connection's upload limits.
+func (c *Conn) WriteMessage(id MessageID, payload []byte) error {
+	if id == MsgPiece && len(c.uploadLimits) > 0 {
+		if err := ratelimit.WaitAll(c.ctx, len(payload), c.uploadLimits...); err != nil {
+			return err
+		}
+	}
+	if _, err := c.Write(buildMessage(id, payload)); err != nil {
+		return err
+	}
+	if id == MsgPiece && c.pieceSent != nil {
+		// the index and begin fields precede the block
+		c.pieceSent(max(len(payload)-8, 0))
+	}
+	return nil
+}
+
+// Await reads messages until one with the given ID arrives. Messages that
+// do not affect the exchange in progress are skipped; a choke aborts the
+// wait with ErrChoked.
+func (c *Conn) Await(id MessageID) (*Message, error) {
+	for {
+		msg, err := c.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case msg.ID == id:
+			return msg, nil
+		case msg.ID == MsgChoke:
+			return nil, ErrChoked
+		}
+	}
+}
+
+// ReadBitfield waits for the peer's bitfield message and returns it.
+func (c *Conn) ReadBitfield() ([]byte, error) {
+	msg, err := c.Await(MsgBitfield)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Payload, nil
+}
+
+// SendInterested tells the peer we are interested and waits until it
+// unchokes us.
+func (c *Conn) SendInterested() error {
+	if err := c.WriteMessage(MsgInterested, nil); err != nil {
+		return err
+	}
+
+	_, err := c.Await(MsgUnchoke)
+	return err
+}
+
+// RequestBlock requests length bytes at offset begin of piece index and
+// waits for the data.
+func (c *Conn) RequestBlock(index, begin, length int) ([]byte, error) {
+	if err := c.WriteMessage(MsgRequest, buildRequest(index, begin, length)); err != nil {
+		return nil, err
+	}
+
+	msg, err := c.Await(MsgPiece)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Payload) < 8 {
+		return nil, fmt.Errorf("piece message too short")
+	}
+	gotIndex := binary.BigEndian.Uint32(msg.Payload[0:4])
+	gotBegin := binary.BigEndian.Uint32(msg.Payload[4:8])
+	data := msg.Payload[8:]
+	if int(gotIndex) != index || int(gotBegin) != begin || len(data) != length {
+		return nil, fmt.Errorf("unexpected block: piece %d offset %d length %d", gotIndex, gotBegin, len(data))
+	}
+	return data, nil
+}
+
+func buildMessage(msgType MessageID, msg []byte) []byte {
+	var length uint32 = uint32(len(msg)) + 1
+
+	payload := make([]byte, 0, 4+1+len(msg))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(length))
+	payload = append(payload, byte(msgType))
+	payload = append(payload, msg...)
+	return payload
+}
+
+func buildRequest(pieceIndex, blockOffset, length int) []byte {
+	payload := make([]byte, 0, 12)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(pieceIndex))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(blockOffset))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(length))
+	return payload
+}
@@ -0,0 +1,126 @@
+// Package ratelimit implements token bucket rate limiting for network
+// transfers.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that refills at a fixed number of bytes per
+// second and holds at most one second worth of tokens. A nil Limiter or
+// one with a zero rate does not limit.
+//
+// A Limiter may go into debt: WaitN takes all n tokens at once and waits
+// until the bucket is no longer negative, so a single call may ask for more
+// than the bucket holds.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter allowing rate bytes per second. A zero rate means
+// unlimited.
+func New(rate int) *Limiter {
+	return &Limiter{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// Rate returns the current rate in bytes per second.
+func (l *Limiter) Rate() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.rate)
+}
+
+// SetRate changes the rate. It takes effect for waits that start after the
+// call.
+func (l *Limiter) SetRate(rate int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate = float64(rate)
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+}
+
+// refill adds the tokens accumulated since the last call. It must be called
+// with l.mu held.
+func (l *Limiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+}
+
+// WaitN blocks until n bytes may be transferred. It returns ctx.Err() if
+// ctx is done first, in which case the tokens are given back.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	return WaitAll(ctx, n, l)
+}
+
+// WaitAll blocks until n bytes may be transferred through every one of
+// limiters, such as the limiters of a session, a torrent and a peer. Nil
+// limiters are ignored. If ctx is done first, it returns ctx.Err() and the
+// tokens are given back.
+func WaitAll(ctx context.Context, n int, limiters ...*Limiter) error {
+	var wait time.Duration
+	taken := make([]*Limiter, 0, len(limiters))
+	for _, l := range limiters {
+		if d, ok := l.take(n); ok {
+			taken = append(taken, l)
+			wait = max(wait, d)
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		for _, l := range taken {
+			l.giveBack(n)
+		}
+		return ctx.Err()
+	}
+}
+
+// take takes n tokens and returns how long to wait until the bucket is no
+// longer in debt. It returns false if l does not limit.
+func (l *Limiter) take(n int) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate == 0 {
+		return 0, false
+	}
+	l.refill(time.Now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0, true
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second)), true
+}
+
+func (l *Limiter) giveBack(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens += float64(n)
+}
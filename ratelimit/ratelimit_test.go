@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		limiters []*Limiter
+		n        int
+		cancel   bool
+		wantErr  error
+		// minWait is how long WaitAll must at least block.
+		minWait time.Duration
+		// tokens are the tokens left in each limiter afterwards; nil and
+		// zero rate limiters hold none.
+		tokens []float64
+	}{
+		{"no limiters", nil, 1 << 30, false, nil, 0, nil},
+		{"nil limiter", []*Limiter{nil}, 1 << 30, false, nil, 0, []float64{0}},
+		{"zero rate", []*Limiter{New(0)}, 1 << 30, false, nil, 0, []float64{0}},
+		{"within the bucket", []*Limiter{New(1000)}, 400, false, nil, 0, []float64{600}},
+		{"debt", []*Limiter{New(100000)}, 105000, false, nil, 50 * time.Millisecond, []float64{-5000}},
+		{
+			"slowest limiter",
+			[]*Limiter{New(100000), New(1000000), nil, New(0)},
+			110000, false, nil, 100 * time.Millisecond,
+			[]float64{-10000, 890000, 0, 0},
+		},
+		{"cancel gives tokens back", []*Limiter{New(1000), New(5000), nil}, 2000, true, context.Canceled, 0, []float64{1000, 5000, 0}},
+		{"cancel without waiting", []*Limiter{New(1000)}, 500, true, nil, 0, []float64{500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			} else {
+				defer cancel()
+			}
+			start := time.Now()
+			err := WaitAll(ctx, tt.n, tt.limiters...)
+			elapsed := time.Since(start)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WaitAll() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed < tt.minWait {
+				t.Errorf("WaitAll() returned after %v, want at least %v", elapsed, tt.minWait)
+			}
+			if tt.minWait == 0 && elapsed > time.Second {
+				t.Errorf("WaitAll() blocked for %v", elapsed)
+			}
+			for i, l := range tt.limiters {
+				var got float64
+				if l != nil {
+					got = l.tokens
+				}
+				if got != tt.tokens[i] {
+					t.Errorf("limiter %d has %v tokens, want %v", i, got, tt.tokens[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetRate(t *testing.T) {
+	l := New(1000)
+	l.SetRate(100)
+	if l.Rate() != 100 || l.tokens != 100 {
+		t.Errorf("after SetRate(100): rate %d, tokens %v, want 100, 100", l.Rate(), l.tokens)
+	}
+	if err := l.WaitN(context.Background(), 50); err != nil {
+		t.Fatal(err)
+	}
+	l.SetRate(0)
+	if err := l.WaitN(context.Background(), 1<<30); err != nil {
+		t.Errorf("WaitN() without a limit error = %v", err)
+	}
+	var nilLimiter *Limiter
+	if nilLimiter.Rate() != 0 {
+		t.Errorf("nil limiter rate = %d, want 0", nilLimiter.Rate())
+	}
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule is a daily time window, such as office hours, used to switch
+// to alternative limits.
+type Schedule struct {
+	// Start and End are offsets from local midnight. A window whose End is
+	// before its Start wraps past midnight, and one whose End equals its
+	// Start lasts the whole day.
+	Start, End time.Duration
+	// Days restricts the window to the days it starts on. Every day is
+	// included if it is empty.
+	Days []time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseSchedule parses a schedule such as "09:00-18:00" or
+// "mon-fri 09:00-18:00". Days are given as three letter names, separated
+// by commas, and ranges of days may wrap around the week, as in "fri-mon".
+// The time range may wrap past midnight, as in "22:00-06:00", but must not
+// be empty.
+func ParseSchedule(s string) (*Schedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid schedule %q", s)
+	}
+	var sched Schedule
+	if len(fields) == 2 {
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		sched.Days = days
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid schedule %q: want a time range such as 09:00-18:00", s)
+	}
+	var err error
+	if sched.Start, err = parseClock(start); err != nil {
+		return nil, err
+	}
+	if sched.End, err = parseClock(end); err != nil {
+		return nil, err
+	}
+	if sched.Start == sched.End {
+		return nil, fmt.Errorf("invalid schedule %q: the time range is empty", s)
+	}
+	return &sched, nil
+}
+
+func parseDays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(strings.ToLower(s), ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return nil, fmt.Errorf("unknown day %q", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Active reports whether t falls into the window.
+func (s *Schedule) Active(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if s.Start < s.End {
+		return offset >= s.Start && offset < s.End && s.onDay(t.Weekday())
+	}
+	// the window wraps past midnight: it is active late on a day it
+	// starts on, or early on the day after
+	if offset >= s.Start {
+		return s.onDay(t.Weekday())
+	}
+	return offset < s.End && s.onDay((t.Weekday()+6)%7)
+}
+
+func (s *Schedule) onDay(d time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, day := range s.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the schedule the way ParseSchedule reads it.
+func (s *Schedule) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	window := clock(s.Start) + "-" + clock(s.End)
+	if len(s.Days) == 0 {
+		return window
+	}
+	names := make([]string, len(s.Days))
+	for i, d := range s.Days {
+		names[i] = strings.ToLower(d.String()[:3])
+	}
+	return strings.Join(names, ",") + " " + window
+}
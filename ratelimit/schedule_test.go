@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *Schedule
+	}{
+		{"09:00-18:00", &Schedule{Start: 9 * time.Hour, End: 18 * time.Hour}},
+		{"22:30-06:00", &Schedule{Start: 22*time.Hour + 30*time.Minute, End: 6 * time.Hour}},
+		{"00:00-23:59", &Schedule{Start: 0, End: 23*time.Hour + 59*time.Minute}},
+		{"mon-fri 09:00-18:00", &Schedule{Start: 9 * time.Hour, End: 18 * time.Hour, Days: []time.Weekday{1, 2, 3, 4, 5}}},
+		{"Fri-Mon 09:00-18:00", &Schedule{Start: 9 * time.Hour, End: 18 * time.Hour, Days: []time.Weekday{5, 6, 0, 1}}},
+		{"sat,sun 10:00-12:00", &Schedule{Start: 10 * time.Hour, End: 12 * time.Hour, Days: []time.Weekday{6, 0}}},
+		// errors
+		{"", nil},
+		{"09:00", nil},
+		{"09:00-09:00", nil},
+		{"mon 00:00-00:00", nil},
+		{"9am-5pm", nil},
+		{"24:00-01:00", nil},
+		{"mon-xyz 09:00-18:00", nil},
+		{"mon tue 09:00-18:00", nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseSchedule(tt.in)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("ParseSchedule(%q) = %+v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSchedule(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if again, err := ParseSchedule(got.String()); err != nil || !reflect.DeepEqual(again, got) {
+			t.Errorf("ParseSchedule(%q) = %v, %v, want %+v", got.String(), again, err, got)
+		}
+	}
+}
+
+func TestScheduleActive(t *testing.T) {
+	// 2026-10-19 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	office := &Schedule{Start: 9 * time.Hour, End: 18 * time.Hour, Days: []time.Weekday{time.Monday, time.Friday}}
+	night := &Schedule{Start: 22 * time.Hour, End: 6 * time.Hour, Days: []time.Weekday{time.Friday}}
+	// Transmission sets equal begin and end times, which make a window
+	// of a whole day from its start
+	allDay := &Schedule{Start: 8 * time.Hour, End: 8 * time.Hour, Days: []time.Weekday{time.Monday}}
+	tests := []struct {
+		name  string
+		sched *Schedule
+		t     time.Time
+		want  bool
+	}{
+		{"office start", office, at(19, 9, 0), true},
+		{"office end", office, at(19, 18, 0), false},
+		{"office before", office, at(19, 8, 59), false},
+		{"office other day", office, at(20, 12, 0), false},
+		{"office friday", office, at(23, 12, 0), true},
+		{"night late on its day", night, at(23, 23, 0), true},
+		{"night early the day after", night, at(24, 5, 59), true},
+		{"night early on its day", night, at(23, 5, 0), false},
+		{"night end", night, at(24, 6, 0), false},
+		{"night late the day after", night, at(24, 23, 0), false},
+		{"whole day before start", allDay, at(19, 3, 0), false},
+		{"whole day start", allDay, at(19, 8, 0), true},
+		{"whole day the day after", allDay, at(20, 7, 59), true},
+		{"whole day end", allDay, at(20, 8, 0), false},
+		{"every day", &Schedule{Start: time.Hour, End: 2 * time.Hour}, at(21, 1, 30), true},
+	}
+	for _, tt := range tests {
+		if got := tt.sched.Active(tt.t); got != tt.want {
+			t.Errorf("%s: %v.Active(%v) = %v, want %v", tt.name, tt.sched, tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
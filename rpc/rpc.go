@@ -0,0 +1,195 @@
+// Package rpc serves a JSON-RPC 2.0 API over HTTP that controls a
+// client.Client. Requests are POSTed to the handler with the content type
+// application/json; batches are supported. A request without the session
+// ID in its X-Session-Id header is answered with 409 Conflict and the ID
+// in that header, to be sent with the following requests. This keeps web
+// pages open in a browser from controlling the client.
+//
+// Torrents are identified by their hex encoded info hash. The methods are:
+//
+//	torrent.add                {path | metainfo | magnet, output_path, paused} -> torrent
+//	torrent.list               {} -> [torrent]
+//	torrent.get                {info_hash} -> torrent with files and peers
+//	torrent.pause              {info_hash} -> torrent
+//	torrent.resume             {info_hash} -> torrent
+//	torrent.remove             {info_hash, delete_data} -> null
+//	torrent.set_file_priorities {info_hash, files, priority} -> torrent with files
+//	torrent.set_limits         {info_hash, download_rate_limit, upload_rate_limit} -> torrent
+//	session.stats              {} -> session
+//	session.set_limits         {any field of limits} -> limits
+//
+// metainfo is the content of a .torrent file encoded in base64. Priorities
+// are "skip", "low", "normal" or "high".
+//
+// TransmissionServer serves the same client through the Transmission RPC
+// protocol instead.
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxRequestSize bounds the size of a request body.
+const maxRequestSize = 32 << 20
+
+// Standard JSON-RPC 2.0 error codes, and the code used for errors returned
+// by the client.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON always includes the result of a successful call, even if it
+// is null.
+func (r response) MarshalJSON() ([]byte, error) {
+	type plain response
+	if r.Error != nil {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{r.JSONRPC, r.Result, r.ID})
+}
+
+// handler runs a method with the raw params of a request.
+type handler func(params json.RawMessage) (interface{}, error)
+
+// ServeHTTP handles a single request or a batch. Requests without the
+// current session ID are answered with 409 Conflict and the ID, as in the
+// Transmission protocol, and requests must have a JSON content type.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkSession(w, r, RPCSessionIDHeader, s.sessionID) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isJSON(r) {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var out interface{}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+			out = errorResponse(nil, CodeInvalidRequest, "invalid batch")
+		} else {
+			var resps []response
+			for _, raw := range batch {
+				if resp, ok := s.call(raw); ok {
+					resps = append(resps, resp)
+				}
+			}
+			if len(resps) > 0 {
+				out = resps
+			}
+		}
+	} else if resp, ok := s.call(body); ok {
+		out = resp
+	}
+
+	if out == nil {
+		// only notifications
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// call runs a single request. It returns false for a notification, which
+// gets no response.
+func (s *Server) call(raw json.RawMessage) (response, bool) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return errorResponse(nil, CodeParseError, err.Error()), true
+		}
+		return errorResponse(nil, CodeInvalidRequest, err.Error()), true
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, "not a JSON-RPC 2.0 request"), true
+	}
+
+	var resp response
+	fn, ok := s.methods[req.Method]
+	if !ok {
+		resp = errorResponse(req.ID, CodeMethodNotFound, "method not found: "+req.Method)
+	} else if result, err := fn(req.Params); err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: CodeServerError, Message: err.Error()}
+		}
+		resp = response{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}
+	} else {
+		resp = response{JSONRPC: "2.0", Result: result, ID: req.ID}
+	}
+	if req.ID == nil {
+		return response{}, false
+	}
+	return resp, true
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return response{JSONRPC: "2.0", Error: &Error{Code: code, Message: msg}, ID: id}
+}
+
+// decodeParams unmarshals the params of a request into v. Missing params
+// leave v unchanged.
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+)
+
+const testHost = "127.0.0.1:9091"
+
+func newTestClient(t *testing.T) *client.Client {
+	t.Helper()
+	c, err := client.NewClient(client.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// post sends body to h as a JSON request from a local tool. change, if
+// not nil, may alter the request first.
+func post(h http.Handler, body string, change func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	r.Host = testHost
+	r.Header.Set("Content-Type", "application/json")
+	if change != nil {
+		change(r)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// call sends body to h with the session ID the server asks for and
+// returns the response body.
+func call(t *testing.T, h http.Handler, header, body string) []byte {
+	t.Helper()
+	id := post(h, "", nil).Header().Get(header)
+	w := post(h, body, func(r *http.Request) { r.Header.Set(header, id) })
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", w.Code, w.Body)
+	}
+	return w.Body.Bytes()
+}
+
+// torrentFile returns a single-file .torrent named name, base64 encoded.
+func torrentFile(name string) string {
+	data := fmt.Sprintf("d4:infod6:lengthi3e4:name%d:%s12:piece lengthi16384e6:pieces20:%se", len(name), name, strings.Repeat("a", 20))
+	return base64.StdEncoding.EncodeToString([]byte(data + "e"))
+}
+
+func TestSession(t *testing.T) {
+	c := newTestClient(t)
+	servers := []struct {
+		name    string
+		handler http.Handler
+		header  string
+		body    string
+	}{
+		{"json-rpc", NewServer(c), RPCSessionIDHeader, `{"jsonrpc": "2.0", "method": "torrent.list", "id": 1}`},
+		{"transmission", NewTransmissionServer(c), SessionIDHeader, `{"method": "session-stats"}`},
+	}
+	for _, srv := range servers {
+		id := post(srv.handler, srv.body, nil).Header().Get(srv.header)
+		if id == "" {
+			t.Fatalf("%s: no session ID in the %s header", srv.name, srv.header)
+		}
+		tests := []struct {
+			name   string
+			change func(r *http.Request)
+			want   int
+		}{
+			{"missing ID", func(r *http.Request) {}, http.StatusConflict},
+			{"wrong ID", func(r *http.Request) { r.Header.Set(srv.header, id+"x") }, http.StatusConflict},
+			{"valid ID", func(r *http.Request) { r.Header.Set(srv.header, id) }, http.StatusOK},
+			{"localhost", func(r *http.Request) { r.Header.Set(srv.header, id); r.Host = "localhost:9091" }, http.StatusOK},
+			{"IPv6 host", func(r *http.Request) { r.Header.Set(srv.header, id); r.Host = "[::1]:9091" }, http.StatusOK},
+			{"rebound host", func(r *http.Request) { r.Header.Set(srv.header, id); r.Host = "evil.example:9091" }, http.StatusForbidden},
+			{"same origin", func(r *http.Request) { r.Header.Set(srv.header, id); r.Header.Set("Origin", "http://"+testHost) }, http.StatusOK},
+			{"other origin", func(r *http.Request) { r.Header.Set(srv.header, id); r.Header.Set("Origin", "http://evil.example") }, http.StatusForbidden},
+			{"null origin", func(r *http.Request) { r.Header.Set(srv.header, id); r.Header.Set("Origin", "null") }, http.StatusForbidden},
+			{"GET", func(r *http.Request) { r.Header.Set(srv.header, id); r.Method = http.MethodGet }, http.StatusMethodNotAllowed},
+		}
+		for _, tt := range tests {
+			w := post(srv.handler, srv.body, tt.change)
+			if w.Code != tt.want {
+				t.Errorf("%s: %s: status = %d, want %d", srv.name, tt.name, w.Code, tt.want)
+			}
+			if w.Code == http.StatusConflict && w.Header().Get(srv.header) != id {
+				t.Errorf("%s: %s: 409 without the session ID", srv.name, tt.name)
+			}
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	s := NewServer(newTestClient(t))
+	id := post(s, "", nil).Header().Get(RPCSessionIDHeader)
+	tests := []struct {
+		contentType string
+		want        int
+	}{
+		{"application/json", http.StatusOK},
+		{"application/json; charset=utf-8", http.StatusOK},
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		w := post(s, `{"jsonrpc": "2.0", "method": "torrent.list", "id": 1}`, func(r *http.Request) {
+			r.Header.Set(RPCSessionIDHeader, id)
+			r.Header.Set("Content-Type", tt.contentType)
+		})
+		if w.Code != tt.want {
+			t.Errorf("Content-Type %q: status = %d, want %d", tt.contentType, w.Code, tt.want)
+		}
+	}
+}
+
+func TestAddRejectsUnsafeNames(t *testing.T) {
+	c := newTestClient(t)
+	for _, name := range []string{"../x", "..", "a/b", `a\b`} {
+		body := fmt.Sprintf(`{"jsonrpc": "2.0", "method": "torrent.add", "params": {"metainfo": %q, "paused": true}, "id": 1}`, torrentFile(name))
+		var resp struct {
+			Error *Error `json:"error"`
+		}
+		if err := json.Unmarshal(call(t, NewServer(c), RPCSessionIDHeader, body), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error == nil {
+			t.Errorf("torrent.add of a torrent named %q succeeded", name)
+		}
+
+		body = fmt.Sprintf(`{"method": "torrent-add", "arguments": {"metainfo": %q, "paused": true}}`, torrentFile(name))
+		var trResp trResponse
+		if err := json.Unmarshal(call(t, NewTransmissionServer(c), SessionIDHeader, body), &trResp); err != nil {
+			t.Fatal(err)
+		}
+		if trResp.Result == "success" {
+			t.Errorf("torrent-add of a torrent named %q succeeded", name)
+		}
+	}
+	if n := len(c.Torrents()); n != 0 {
+		t.Errorf("client has %d torrents, want none", n)
+	}
+
+	body := fmt.Sprintf(`{"jsonrpc": "2.0", "method": "torrent.add", "params": {"metainfo": %q, "paused": true}, "id": 1}`, torrentFile("x"))
+	var resp struct {
+		Error *Error `json:"error"`
+	}
+	if err := json.Unmarshal(call(t, NewServer(c), RPCSessionIDHeader, body), &resp); err != nil || resp.Error != nil {
+		t.Errorf("torrent.add of a torrent named \"x\" = %v, %v", resp.Error, err)
+	}
+}
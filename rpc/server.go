@@ -0,0 +1,438 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+)
+
+// Server is an http.Handler serving the JSON-RPC API of a client.
+type Server struct {
+	client    *client.Client
+	sessionID string
+	methods   map[string]handler
+}
+
+// NewServer returns a Server controlling c.
+func NewServer(c *client.Client) *Server {
+	s := &Server{client: c, sessionID: newSessionID()}
+	s.methods = map[string]handler{
+		"torrent.add":                 s.add,
+		"torrent.list":                s.list,
+		"torrent.get":                 s.get,
+		"torrent.pause":               s.pause,
+		"torrent.resume":              s.resume,
+		"torrent.remove":              s.remove,
+		"torrent.set_file_priorities": s.setFilePriorities,
+		"session.stats":               s.stats,
+		"torrent.set_limits":          s.setTorrentLimits,
+		"session.set_limits":          s.setLimits,
+	}
+	return s
+}
+
+// Torrent is the status of a torrent as returned by the API. Files and
+// Peers are only filled in by torrent.get.
+type Torrent struct {
+	InfoHash        string `json:"info_hash"`
+	Name            string `json:"name"`
+	State           string `json:"state"`
+	Error           string `json:"error,omitempty"`
+	PeersConnected  int    `json:"peers_connected"`
+	PiecesTotal     int    `json:"pieces_total"`
+	PiecesVerified  int    `json:"pieces_verified"`
+	BytesTotal      int64  `json:"bytes_total"`
+	BytesWanted     int64  `json:"bytes_wanted"`
+	BytesCompleted  int64  `json:"bytes_completed"`
+	BytesDownloaded int64  `json:"bytes_downloaded"`
+	BytesUploaded   int64  `json:"bytes_uploaded"`
+	DownloadRate    int    `json:"download_rate"`
+	UploadRate      int    `json:"upload_rate"`
+	// DownloadRateLimit and UploadRateLimit are the torrent's own limits.
+	DownloadRateLimit int    `json:"download_rate_limit"`
+	UploadRateLimit   int    `json:"upload_rate_limit"`
+	Files             []File `json:"files,omitempty"`
+	Peers             []Peer `json:"peers,omitempty"`
+}
+
+// File is the status of one file of a torrent.
+type File struct {
+	Path           string          `json:"path"`
+	Length         int64           `json:"length"`
+	Priority       client.Priority `json:"priority"`
+	BytesCompleted int64           `json:"bytes_completed"`
+}
+
+// Peer is the status of a connection to a peer.
+type Peer struct {
+	Addr         string `json:"addr"`
+	PeerID       string `json:"peer_id"`
+	Incoming     bool   `json:"incoming"`
+	Downloaded   int64  `json:"downloaded"`
+	Uploaded     int64  `json:"uploaded"`
+	DownloadRate int    `json:"download_rate"`
+	UploadRate   int    `json:"upload_rate"`
+}
+
+// Session is the status of the client as returned by session.stats.
+type Session struct {
+	PeerID          string `json:"peer_id"`
+	Torrents        int    `json:"torrents"`
+	Connections     int    `json:"connections"`
+	DownloadRate    int    `json:"download_rate"`
+	UploadRate      int    `json:"upload_rate"`
+	BytesDownloaded int64  `json:"bytes_downloaded"`
+	BytesUploaded   int64  `json:"bytes_uploaded"`
+	AltLimitsActive bool   `json:"alt_limits_active"`
+	Limits          Limits `json:"limits"`
+}
+
+// Limits are the limits of the client. Rates are in bytes per second and
+// zero means no limit. AltSchedule is formatted like "mon-fri 09:00-18:00"
+// and empty if there is none.
+type Limits struct {
+	MaxPeers              int    `json:"max_peers"`
+	MaxConnections        int    `json:"max_connections"`
+	DownloadRateLimit     int    `json:"download_rate_limit"`
+	UploadRateLimit       int    `json:"upload_rate_limit"`
+	PeerDownloadRateLimit int    `json:"peer_download_rate_limit"`
+	PeerUploadRateLimit   int    `json:"peer_upload_rate_limit"`
+	AltDownloadRateLimit  int    `json:"alt_download_rate_limit"`
+	AltUploadRateLimit    int    `json:"alt_upload_rate_limit"`
+	AltSchedule           string `json:"alt_schedule"`
+	AltEnabled            bool   `json:"alt_enabled"`
+}
+
+func limitsStatus(l client.Limits) Limits {
+	status := Limits{
+		MaxPeers:              l.MaxPeers,
+		MaxConnections:        l.MaxConnections,
+		DownloadRateLimit:     l.DownloadRateLimit,
+		UploadRateLimit:       l.UploadRateLimit,
+		PeerDownloadRateLimit: l.PeerDownloadRateLimit,
+		PeerUploadRateLimit:   l.PeerUploadRateLimit,
+		AltDownloadRateLimit:  l.AltDownloadRateLimit,
+		AltUploadRateLimit:    l.AltUploadRateLimit,
+		AltEnabled:            l.AltEnabled,
+	}
+	if l.AltSchedule != nil {
+		status.AltSchedule = l.AltSchedule.String()
+	}
+	return status
+}
+
+func torrentStatus(t *client.Torrent) Torrent {
+	s := t.Stats()
+	status := Torrent{
+		InfoHash:        hex.EncodeToString(t.InfoHash()),
+		Name:            t.Name(),
+		State:           s.State.String(),
+		PeersConnected:  s.Peers,
+		PiecesTotal:     s.PiecesTotal,
+		PiecesVerified:  s.PiecesVerified,
+		BytesTotal:      s.BytesTotal,
+		BytesWanted:     s.BytesWanted,
+		BytesCompleted:  s.BytesCompleted,
+		BytesDownloaded: s.BytesDownloaded,
+		BytesUploaded:   s.BytesUploaded,
+		DownloadRate:    s.DownloadRate,
+		UploadRate:      s.UploadRate,
+	}
+	if s.Err != nil {
+		status.Error = s.Err.Error()
+	}
+	status.DownloadRateLimit, status.UploadRateLimit = t.RateLimits()
+	return status
+}
+
+func withFiles(status Torrent, t *client.Torrent) Torrent {
+	status.Files = []File{}
+	for _, f := range t.Files() {
+		status.Files = append(status.Files, File{
+			Path:           f.Path,
+			Length:         f.Length,
+			Priority:       f.Priority,
+			BytesCompleted: f.BytesCompleted,
+		})
+	}
+	return status
+}
+
+type torrentParams struct {
+	InfoHash string `json:"info_hash"`
+}
+
+// torrent returns the torrent identified by the hex info hash.
+func (s *Server) torrent(infoHash string) (*client.Torrent, error) {
+	b, err := hex.DecodeString(infoHash)
+	if err != nil || len(b) != 20 {
+		return nil, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid info hash %q", infoHash)}
+	}
+	t := s.client.Torrent(b)
+	if t == nil {
+		return nil, fmt.Errorf("torrent %s not found", infoHash)
+	}
+	return t, nil
+}
+
+func (s *Server) add(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Path       string `json:"path"`
+		Metainfo   string `json:"metainfo"`
+		Magnet     string `json:"magnet"`
+		OutputPath string `json:"output_path"`
+		Paused     bool   `json:"paused"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	var t *client.Torrent
+	var err error
+	switch {
+	case p.Path != "" && p.Metainfo == "" && p.Magnet == "":
+		t, err = s.client.AddTorrentFile(p.Path)
+	case p.Metainfo != "" && p.Path == "" && p.Magnet == "":
+		var info *metainfo.TorrentInfo
+		info, err = parseMetainfo(p.Metainfo)
+		if err == nil {
+			t, err = s.client.AddTorrentInfo(info)
+		}
+	case p.Magnet != "" && p.Path == "" && p.Metainfo == "":
+		t, err = s.client.AddMagnet(p.Magnet)
+	default:
+		return nil, &Error{Code: CodeInvalidParams, Message: "exactly one of path, metainfo and magnet is required"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.OutputPath != "" {
+		t.SetOutputPath(p.OutputPath)
+	}
+	if !p.Paused {
+		if err := t.Start(); err != nil {
+			return nil, err
+		}
+	}
+	return torrentStatus(t), nil
+}
+
+// parseMetainfo parses the base64 encoded content of a .torrent file.
+func parseMetainfo(b64 string) (*metainfo.TorrentInfo, error) {
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "metainfo is not valid base64"}
+	}
+	return metainfo.FromBytes(data)
+}
+
+func (s *Server) list(params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	torrents := []Torrent{}
+	for _, t := range s.client.Torrents() {
+		torrents = append(torrents, torrentStatus(t))
+	}
+	return torrents, nil
+}
+
+func (s *Server) get(params json.RawMessage) (interface{}, error) {
+	var p torrentParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.torrent(p.InfoHash)
+	if err != nil {
+		return nil, err
+	}
+
+	status := withFiles(torrentStatus(t), t)
+	status.Peers = []Peer{}
+	for _, peer := range t.Peers() {
+		status.Peers = append(status.Peers, Peer{
+			Addr:         peer.Addr,
+			PeerID:       hex.EncodeToString([]byte(peer.PeerID)),
+			Incoming:     peer.Incoming,
+			Downloaded:   peer.Downloaded,
+			Uploaded:     peer.Uploaded,
+			DownloadRate: peer.DownloadRate,
+			UploadRate:   peer.UploadRate,
+		})
+	}
+	return status, nil
+}
+
+func (s *Server) pause(params json.RawMessage) (interface{}, error) {
+	var p torrentParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.torrent(p.InfoHash)
+	if err != nil {
+		return nil, err
+	}
+	t.Pause()
+	return torrentStatus(t), nil
+}
+
+func (s *Server) resume(params json.RawMessage) (interface{}, error) {
+	var p torrentParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.torrent(p.InfoHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Start(); err != nil {
+		return nil, err
+	}
+	return torrentStatus(t), nil
+}
+
+func (s *Server) remove(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		InfoHash   string `json:"info_hash"`
+		DeleteData bool   `json:"delete_data"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.torrent(p.InfoHash)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.client.Remove(t.InfoHash(), p.DeleteData)
+}
+
+func (s *Server) setFilePriorities(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		InfoHash string          `json:"info_hash"`
+		Files    []int           `json:"files"`
+		Priority client.Priority `json:"priority"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.torrent(p.InfoHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.SetFilePriorities(p.Files, p.Priority); err != nil {
+		return nil, err
+	}
+	return withFiles(torrentStatus(t), t), nil
+}
+
+func (s *Server) setTorrentLimits(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		InfoHash          string `json:"info_hash"`
+		DownloadRateLimit *int   `json:"download_rate_limit"`
+		UploadRateLimit   *int   `json:"upload_rate_limit"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.torrent(p.InfoHash)
+	if err != nil {
+		return nil, err
+	}
+	download, upload := t.RateLimits()
+	if err := setLimit(&download, p.DownloadRateLimit); err != nil {
+		return nil, err
+	}
+	if err := setLimit(&upload, p.UploadRateLimit); err != nil {
+		return nil, err
+	}
+	t.SetRateLimits(download, upload)
+	return torrentStatus(t), nil
+}
+
+// setLimit sets *dst to *v if v is not nil.
+func setLimit(dst *int, v *int) error {
+	if v == nil {
+		return nil
+	}
+	if *v < 0 {
+		return &Error{Code: CodeInvalidParams, Message: "limits must not be negative"}
+	}
+	*dst = *v
+	return nil
+}
+
+func (s *Server) stats(params json.RawMessage) (interface{}, error) {
+	if err := decodeParams(params, &struct{}{}); err != nil {
+		return nil, err
+	}
+	st := s.client.Stats()
+	return Session{
+		PeerID:          s.client.PeerID(),
+		Torrents:        st.Torrents,
+		Connections:     st.Connections,
+		DownloadRate:    st.DownloadRate,
+		UploadRate:      st.UploadRate,
+		BytesDownloaded: st.BytesDownloaded,
+		BytesUploaded:   st.BytesUploaded,
+		AltLimitsActive: st.AltLimitsActive,
+		Limits:          limitsStatus(s.client.Limits()),
+	}, nil
+}
+
+func (s *Server) setLimits(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		MaxPeers              *int    `json:"max_peers"`
+		MaxConnections        *int    `json:"max_connections"`
+		DownloadRateLimit     *int    `json:"download_rate_limit"`
+		UploadRateLimit       *int    `json:"upload_rate_limit"`
+		PeerDownloadRateLimit *int    `json:"peer_download_rate_limit"`
+		PeerUploadRateLimit   *int    `json:"peer_upload_rate_limit"`
+		AltDownloadRateLimit  *int    `json:"alt_download_rate_limit"`
+		AltUploadRateLimit    *int    `json:"alt_upload_rate_limit"`
+		AltSchedule           *string `json:"alt_schedule"`
+		AltEnabled            *bool   `json:"alt_enabled"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	limits := s.client.Limits()
+	for _, l := range []struct {
+		dst *int
+		v   *int
+	}{
+		{&limits.MaxPeers, p.MaxPeers},
+		{&limits.MaxConnections, p.MaxConnections},
+		{&limits.DownloadRateLimit, p.DownloadRateLimit},
+		{&limits.UploadRateLimit, p.UploadRateLimit},
+		{&limits.PeerDownloadRateLimit, p.PeerDownloadRateLimit},
+		{&limits.PeerUploadRateLimit, p.PeerUploadRateLimit},
+		{&limits.AltDownloadRateLimit, p.AltDownloadRateLimit},
+		{&limits.AltUploadRateLimit, p.AltUploadRateLimit},
+	} {
+		if err := setLimit(l.dst, l.v); err != nil {
+			return nil, err
+		}
+	}
+	if p.AltSchedule != nil {
+		limits.AltSchedule = nil
+		if *p.AltSchedule != "" {
+			sched, err := ratelimit.ParseSchedule(*p.AltSchedule)
+			if err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+			}
+			limits.AltSchedule = sched
+		}
+	}
+	if p.AltEnabled != nil {
+		limits.AltEnabled = *p.AltEnabled
+	}
+	s.client.SetLimits(limits)
+	return limitsStatus(limits), nil
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// RPCSessionIDHeader is the header carrying the session ID of the JSON-RPC
+// API.
+const RPCSessionIDHeader = "X-Session-Id"
+
+// newSessionID returns a random session ID.
+func newSessionID() string {
+	id := make([]byte, 24)
+	rand.Read(id)
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// checkSession answers r with an error and returns false unless it carries
+// the session ID id in header and may not come from a web page of another
+// site. A missing or wrong ID is answered with 409 Conflict and the ID,
+// which the client is expected to send back. A web page cannot read that
+// header from another origin, so it cannot make requests of its own.
+func checkSession(w http.ResponseWriter, r *http.Request, header, id string) bool {
+	if err := checkOrigin(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	w.Header().Set(header, id)
+	if r.Header.Get(header) != id {
+		http.Error(w, "invalid or missing "+header+" header", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// checkOrigin rejects requests that a web page may have sent: those with a
+// Host that is a name other than localhost, which a page of another site
+// may point at this server through DNS rebinding, and those with an Origin
+// other than the server itself.
+func checkOrigin(r *http.Request) error {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if host != "localhost" && net.ParseIP(host) == nil {
+		return fmt.Errorf("host %q not allowed, use an IP address or localhost", r.Host)
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return fmt.Errorf("origin %q not allowed", origin)
+		}
+	}
+	return nil
+}
+
+// isJSON reports whether r has a JSON body. Web pages cannot send one to
+// another site without asking it first through CORS, which is not served.
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
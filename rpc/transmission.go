@@ -0,0 +1,869 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+	"github.com/codecrafters-io/bittorrent-starter-go/magnet"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+	"github.com/codecrafters-io/bittorrent-starter-go/ratelimit"
+)
+
+// SessionIDHeader is the header carrying the Transmission session ID.
+const SessionIDHeader = "X-Transmission-Session-Id"
+
+// Transmission RPC version we implement a subset of, as reported by
+// session-get.
+const (
+	transmissionVersion       = "4.0.0 (bittorrent-starter-go)"
+	transmissionRPCVersion    = 17
+	transmissionRPCVersionMin = 14
+	transmissionRPCSemver     = "5.3.0"
+)
+
+// Torrent status codes of the Transmission protocol.
+const (
+	trStatusStopped  = 0
+	trStatusDownload = 4
+	trStatusSeed     = 6
+)
+
+// trErrorLocal is the Transmission error code for a local error.
+const trErrorLocal = 3
+
+// Transmission counts speeds in kB/s of 1000 bytes.
+const trSpeedBytes = 1000
+
+// fetchTimeout bounds the download of a .torrent file added by URL.
+const fetchTimeout = 30 * time.Second
+
+// TransmissionServer is an http.Handler implementing a subset of the
+// Transmission RPC protocol on top of a client, so that tools written for
+// Transmission, such as transmission-remote, can drive it. It is usually
+// served on /transmission/rpc.
+//
+// Speed limits map to the rate limits of the client, and the alternative
+// speed limits and their schedule to its alternative limits.
+//
+// The supported methods are session-get, session-set, session-stats,
+// torrent-add, torrent-get, torrent-set, torrent-start, torrent-start-now,
+// torrent-stop and torrent-remove. Stopping a torrent pauses it, since
+// Transmission keeps the data of stopped torrents.
+type TransmissionServer struct {
+	client    *client.Client
+	sessionID string
+	started   time.Time
+	methods   map[string]handler
+
+	// Transmission keeps limits while they are disabled, which
+	// client.Limits cannot express, so they are remembered here. Speeds
+	// are in kB/s.
+	mu                    sync.Mutex
+	speedLimitDown        int
+	speedLimitDownEnabled bool
+	speedLimitUp          int
+	speedLimitUpEnabled   bool
+	altSchedule           ratelimit.Schedule
+	altScheduleEnabled    bool
+}
+
+// NewTransmissionServer returns a TransmissionServer controlling c.
+func NewTransmissionServer(c *client.Client) *TransmissionServer {
+	limits := c.Limits()
+	s := &TransmissionServer{
+		client:                c,
+		sessionID:             newSessionID(),
+		started:               time.Now(),
+		speedLimitDown:        limits.DownloadRateLimit / trSpeedBytes,
+		speedLimitDownEnabled: limits.DownloadRateLimit > 0,
+		speedLimitUp:          limits.UploadRateLimit / trSpeedBytes,
+		speedLimitUpEnabled:   limits.UploadRateLimit > 0,
+		// Transmission's default
+		altSchedule: ratelimit.Schedule{Start: 9 * time.Hour, End: 17 * time.Hour},
+	}
+	if limits.AltSchedule != nil {
+		s.altSchedule = *limits.AltSchedule
+		s.altScheduleEnabled = true
+	}
+	s.methods = map[string]handler{
+		"session-get":       s.sessionGet,
+		"session-set":       s.sessionSet,
+		"session-stats":     s.sessionStats,
+		"torrent-add":       s.torrentAdd,
+		"torrent-get":       s.torrentGet,
+		"torrent-set":       s.torrentSet,
+		"torrent-start":     s.torrentStart,
+		"torrent-start-now": s.torrentStart,
+		"torrent-stop":      s.torrentStop,
+		"torrent-remove":    s.torrentRemove,
+	}
+	return s
+}
+
+type trRequest struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments"`
+	Tag       json.RawMessage `json:"tag,omitempty"`
+}
+
+type trResponse struct {
+	Result    string          `json:"result"`
+	Arguments interface{}     `json:"arguments"`
+	Tag       json.RawMessage `json:"tag,omitempty"`
+}
+
+// ServeHTTP handles a Transmission RPC request. Requests without the
+// current session ID are answered with 409 Conflict and the ID, which the
+// client is expected to send back.
+func (s *TransmissionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !checkSession(w, r, SessionIDHeader, s.sessionID) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req trRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := trResponse{Result: "success", Arguments: struct{}{}, Tag: req.Tag}
+	fn, ok := s.methods[req.Method]
+	if !ok {
+		resp.Result = "method name not recognized"
+	} else if args, err := fn(req.Arguments); err != nil {
+		resp.Result = err.Error()
+	} else if args != nil {
+		resp.Arguments = args
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// decodeArgs unmarshals the arguments of a request into v. Unknown
+// arguments are ignored, as Transmission does.
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 || string(args) == "null" {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+// torrents returns the torrents selected by the "ids" argument: a torrent
+// ID, a hash string, a list of both, or "recently-active". All torrents are
+// selected if ids is missing.
+func (s *TransmissionServer) torrents(ids json.RawMessage) ([]*client.Torrent, error) {
+	all := s.client.Torrents()
+	if len(ids) == 0 || string(ids) == "null" {
+		return all, nil
+	}
+
+	var list []interface{}
+	var one interface{}
+	if err := json.Unmarshal(ids, &one); err != nil {
+		return nil, fmt.Errorf("invalid ids: %v", err)
+	}
+	switch v := one.(type) {
+	case []interface{}:
+		list = v
+	case string:
+		if v == "recently-active" {
+			var active []*client.Torrent
+			for _, t := range all {
+				if t.Stats().State == client.StateDownloading {
+					active = append(active, t)
+				}
+			}
+			return active, nil
+		}
+		list = []interface{}{v}
+	default:
+		list = []interface{}{v}
+	}
+
+	var selected []*client.Torrent
+	for _, id := range list {
+		for _, t := range all {
+			if matchID(t, id) {
+				selected = append(selected, t)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
+
+func matchID(t *client.Torrent, id interface{}) bool {
+	switch v := id.(type) {
+	case float64:
+		return int(v) == t.ID()
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n == t.ID()
+		}
+		return strings.EqualFold(v, hex.EncodeToString(t.InfoHash()))
+	}
+	return false
+}
+
+func (s *TransmissionServer) sessionGet(args json.RawMessage) (interface{}, error) {
+	config := s.client.Config()
+	limits := s.client.Limits()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	downloadDir, _ := filepath.Abs(config.DownloadDir)
+	return map[string]interface{}{
+		"version":                  transmissionVersion,
+		"rpc-version":              transmissionRPCVersion,
+		"rpc-version-minimum":      transmissionRPCVersionMin,
+		"rpc-version-semver":       transmissionRPCSemver,
+		"session-id":               s.sessionID,
+		"download-dir":             downloadDir,
+		"peer-port":                config.Port,
+		"peer-limit-global":        limits.MaxConnections,
+		"peer-limit-per-torrent":   limits.MaxPeers,
+		"speed-limit-down":         s.speedLimitDown,
+		"speed-limit-down-enabled": s.speedLimitDownEnabled,
+		"speed-limit-up":           s.speedLimitUp,
+		"speed-limit-up-enabled":   s.speedLimitUpEnabled,
+		"alt-speed-down":           limits.AltDownloadRateLimit / trSpeedBytes,
+		"alt-speed-up":             limits.AltUploadRateLimit / trSpeedBytes,
+		"alt-speed-enabled":        limits.AltEnabled,
+		"alt-speed-time-enabled":   s.altScheduleEnabled,
+		"alt-speed-time-begin":     int(s.altSchedule.Start.Minutes()),
+		"alt-speed-time-end":       int(s.altSchedule.End.Minutes()),
+		"alt-speed-time-day":       trDays(s.altSchedule.Days),
+		"units": map[string]interface{}{
+			"speed-units":  []string{"kB/s", "MB/s", "GB/s", "TB/s"},
+			"speed-bytes":  trSpeedBytes,
+			"size-units":   []string{"kB", "MB", "GB", "TB"},
+			"size-bytes":   1000,
+			"memory-units": []string{"KiB", "MiB", "GiB", "TiB"},
+			"memory-bytes": 1024,
+		},
+	}, nil
+}
+
+// trDays converts days to Transmission's bit mask, in which Sunday is 1
+// and Saturday is 64.
+func trDays(days []time.Weekday) int {
+	if len(days) == 0 {
+		return 127
+	}
+	mask := 0
+	for _, d := range days {
+		mask |= 1 << d
+	}
+	return mask
+}
+
+// fromTRDays converts a Transmission day mask to days.
+func fromTRDays(mask int) []time.Weekday {
+	if mask&127 == 127 {
+		return nil
+	}
+	var days []time.Weekday
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if mask&(1<<d) != 0 {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+func (s *TransmissionServer) sessionSet(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		SpeedLimitDown        *int  `json:"speed-limit-down"`
+		SpeedLimitDownEnabled *bool `json:"speed-limit-down-enabled"`
+		SpeedLimitUp          *int  `json:"speed-limit-up"`
+		SpeedLimitUpEnabled   *bool `json:"speed-limit-up-enabled"`
+		AltSpeedDown          *int  `json:"alt-speed-down"`
+		AltSpeedUp            *int  `json:"alt-speed-up"`
+		AltSpeedEnabled       *bool `json:"alt-speed-enabled"`
+		AltSpeedTimeEnabled   *bool `json:"alt-speed-time-enabled"`
+		AltSpeedTimeBegin     *int  `json:"alt-speed-time-begin"`
+		AltSpeedTimeEnd       *int  `json:"alt-speed-time-end"`
+		AltSpeedTimeDay       *int  `json:"alt-speed-time-day"`
+		PeerLimitGlobal       *int  `json:"peer-limit-global"`
+		PeerLimitPerTorrent   *int  `json:"peer-limit-per-torrent"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setInt := func(dst *int, v *int) {
+		if v != nil {
+			*dst = max(*v, 0)
+		}
+	}
+	setBool := func(dst *bool, v *bool) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setInt(&s.speedLimitDown, a.SpeedLimitDown)
+	setBool(&s.speedLimitDownEnabled, a.SpeedLimitDownEnabled)
+	setInt(&s.speedLimitUp, a.SpeedLimitUp)
+	setBool(&s.speedLimitUpEnabled, a.SpeedLimitUpEnabled)
+	setBool(&s.altScheduleEnabled, a.AltSpeedTimeEnabled)
+	if a.AltSpeedTimeBegin != nil {
+		s.altSchedule.Start = time.Duration(*a.AltSpeedTimeBegin) * time.Minute
+	}
+	if a.AltSpeedTimeEnd != nil {
+		s.altSchedule.End = time.Duration(*a.AltSpeedTimeEnd) * time.Minute
+	}
+	if a.AltSpeedTimeDay != nil {
+		s.altSchedule.Days = fromTRDays(*a.AltSpeedTimeDay)
+	}
+
+	limits := s.client.Limits()
+	limits.DownloadRateLimit = 0
+	if s.speedLimitDownEnabled {
+		limits.DownloadRateLimit = s.speedLimitDown * trSpeedBytes
+	}
+	limits.UploadRateLimit = 0
+	if s.speedLimitUpEnabled {
+		limits.UploadRateLimit = s.speedLimitUp * trSpeedBytes
+	}
+	if a.AltSpeedDown != nil {
+		limits.AltDownloadRateLimit = max(*a.AltSpeedDown, 0) * trSpeedBytes
+	}
+	if a.AltSpeedUp != nil {
+		limits.AltUploadRateLimit = max(*a.AltSpeedUp, 0) * trSpeedBytes
+	}
+	setBool(&limits.AltEnabled, a.AltSpeedEnabled)
+	limits.AltSchedule = nil
+	if s.altScheduleEnabled {
+		sched := s.altSchedule
+		limits.AltSchedule = &sched
+	}
+	setInt(&limits.MaxConnections, a.PeerLimitGlobal)
+	setInt(&limits.MaxPeers, a.PeerLimitPerTorrent)
+	s.client.SetLimits(limits)
+	return nil, nil
+}
+
+func (s *TransmissionServer) sessionStats(args json.RawMessage) (interface{}, error) {
+	st := s.client.Stats()
+	active, paused := 0, 0
+	for _, t := range s.client.Torrents() {
+		switch t.Stats().State {
+		case client.StateDownloading:
+			active++
+		case client.StatePaused, client.StateStopped:
+			paused++
+		}
+	}
+	stats := map[string]interface{}{
+		"downloadedBytes": st.BytesDownloaded,
+		"uploadedBytes":   st.BytesUploaded,
+		"filesAdded":      st.Torrents,
+		"sessionCount":    1,
+		"secondsActive":   int(time.Since(s.started).Seconds()),
+	}
+	return map[string]interface{}{
+		"activeTorrentCount": active,
+		"pausedTorrentCount": paused,
+		"torrentCount":       st.Torrents,
+		"downloadSpeed":      st.DownloadRate,
+		"uploadSpeed":        st.UploadRate,
+		"cumulative-stats":   stats,
+		"current-stats":      stats,
+	}, nil
+}
+
+func (s *TransmissionServer) torrentAdd(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Filename       string `json:"filename"`
+		Metainfo       string `json:"metainfo"`
+		DownloadDir    string `json:"download-dir"`
+		Paused         bool   `json:"paused"`
+		FilesWanted    []int  `json:"files-wanted"`
+		FilesUnwanted  []int  `json:"files-unwanted"`
+		PriorityHigh   []int  `json:"priority-high"`
+		PriorityLow    []int  `json:"priority-low"`
+		PriorityNormal []int  `json:"priority-normal"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+
+	var info *metainfo.TorrentInfo
+	var infoHash []byte
+	switch {
+	case a.Metainfo != "":
+		data, err := base64.StdEncoding.DecodeString(a.Metainfo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid or corrupt torrent file")
+		}
+		if info, err = parseTorrent(data); err != nil {
+			return nil, err
+		}
+		infoHash = info.InfoHash
+	case strings.HasPrefix(a.Filename, "magnet:"):
+		mag, err := magnet.Parse(a.Filename)
+		if err != nil {
+			return nil, err
+		}
+		infoHash = mag.InfoHash
+	case strings.HasPrefix(a.Filename, "http://"), strings.HasPrefix(a.Filename, "https://"):
+		data, err := fetchTorrent(a.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if info, err = parseTorrent(data); err != nil {
+			return nil, err
+		}
+		infoHash = info.InfoHash
+	case a.Filename != "":
+		var err error
+		if info, err = metainfo.FromFile(a.Filename); err != nil {
+			return nil, err
+		}
+		infoHash = info.InfoHash
+	default:
+		return nil, fmt.Errorf("no filename or metainfo specified")
+	}
+
+	if t := s.client.Torrent(infoHash); t != nil {
+		return map[string]interface{}{"torrent-duplicate": addedTorrent(t)}, nil
+	}
+	var t *client.Torrent
+	var err error
+	if info != nil {
+		t, err = s.client.AddTorrentInfo(info)
+	} else {
+		t, err = s.client.AddMagnet(a.Filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if a.DownloadDir != "" {
+		t.SetDownloadDir(a.DownloadDir)
+	}
+	if info != nil {
+		if err := setFiles(t, a.FilesWanted, a.FilesUnwanted, a.PriorityHigh, a.PriorityLow, a.PriorityNormal); err != nil {
+			s.client.Remove(infoHash, false)
+			return nil, err
+		}
+	}
+	if !a.Paused {
+		if err := t.Start(); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{"torrent-added": addedTorrent(t)}, nil
+}
+
+func addedTorrent(t *client.Torrent) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         t.ID(),
+		"name":       t.Name(),
+		"hashString": hex.EncodeToString(t.InfoHash()),
+	}
+}
+
+// parseTorrent parses the content of a .torrent file.
+func parseTorrent(data []byte) (*metainfo.TorrentInfo, error) {
+	torrentInfo, err := metainfo.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or corrupt torrent file: %v", err)
+	}
+	return torrentInfo, nil
+}
+
+// fetchTorrent downloads a .torrent file.
+func fetchTorrent(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRequestSize))
+}
+
+// setFiles applies the wanted and priority arguments of torrent-add and
+// torrent-set. An empty list that is present selects every file.
+// Priorities only change files that are wanted.
+func setFiles(t *client.Torrent, wanted, unwanted, high, low, normal []int) error {
+	files := t.Files()
+	if files == nil {
+		return nil
+	}
+	expand := func(list []int) []int {
+		if list == nil || len(list) > 0 {
+			return list
+		}
+		all := make([]int, len(files))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	var toWant []int
+	for _, f := range expand(wanted) {
+		if f >= 0 && f < len(files) && files[f].Priority == client.PrioritySkip {
+			toWant = append(toWant, f)
+		}
+	}
+	if err := t.SetFilePriorities(toWant, client.PriorityNormal); err != nil {
+		return err
+	}
+	if err := t.SetFilePriorities(expand(unwanted), client.PrioritySkip); err != nil {
+		return err
+	}
+
+	files = t.Files()
+	for _, set := range []struct {
+		list []int
+		prio client.Priority
+	}{{high, client.PriorityHigh}, {low, client.PriorityLow}, {normal, client.PriorityNormal}} {
+		var list []int
+		for _, f := range expand(set.list) {
+			if f < 0 || f >= len(files) {
+				return fmt.Errorf("file index %d out of range", f)
+			}
+			if files[f].Priority != client.PrioritySkip {
+				list = append(list, f)
+			}
+		}
+		if err := t.SetFilePriorities(list, set.prio); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TransmissionServer) torrentGet(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		IDs    json.RawMessage `json:"ids"`
+		Fields []string        `json:"fields"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	torrents, err := s.torrents(a.IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []map[string]interface{}{}
+	for _, t := range torrents {
+		fields := map[string]interface{}{}
+		st := t.Stats()
+		for _, f := range a.Fields {
+			if v, ok := torrentField(t, st, f); ok {
+				fields[f] = v
+			}
+		}
+		list = append(list, fields)
+	}
+	resp := map[string]interface{}{"torrents": list}
+	if string(a.IDs) == `"recently-active"` {
+		resp["removed"] = []int{}
+	}
+	return resp, nil
+}
+
+// torrentField returns the value of a torrent-get field. It returns false
+// for fields that are not supported.
+func torrentField(t *client.Torrent, st client.Stats, field string) (interface{}, bool) {
+	info := t.Info()
+	switch field {
+	case "id":
+		return t.ID(), true
+	case "queuePosition":
+		return t.ID() - 1, true
+	case "hashString":
+		return hex.EncodeToString(t.InfoHash()), true
+	case "name":
+		return t.Name(), true
+	case "status":
+		switch st.State {
+		case client.StateDownloading:
+			return trStatusDownload, true
+		case client.StateCompleted:
+			return trStatusSeed, true
+		}
+		return trStatusStopped, true
+	case "error":
+		if st.Err != nil {
+			return trErrorLocal, true
+		}
+		return 0, true
+	case "errorString":
+		if st.Err != nil {
+			return st.Err.Error(), true
+		}
+		return "", true
+	case "isFinished":
+		return st.State == client.StateCompleted, true
+	case "isStalled":
+		return st.State == client.StateDownloading && st.DownloadRate == 0, true
+	case "totalSize":
+		return st.BytesTotal, true
+	case "sizeWhenDone":
+		return st.BytesWanted, true
+	case "leftUntilDone":
+		return st.BytesLeft, true
+	case "haveValid":
+		return st.BytesCompleted, true
+	case "haveUnchecked", "uploadRatio", "peersGettingFromUs", "corruptEver":
+		return 0, true
+	case "percentDone":
+		if st.BytesWanted == 0 {
+			return 0.0, true
+		}
+		return float64(st.BytesWanted-st.BytesLeft) / float64(st.BytesWanted), true
+	case "percentComplete":
+		if st.BytesTotal == 0 {
+			return 0.0, true
+		}
+		return float64(st.BytesCompleted) / float64(st.BytesTotal), true
+	case "metadataPercentComplete":
+		if info == nil {
+			return 0.0, true
+		}
+		return 1.0, true
+	case "rateDownload":
+		return st.DownloadRate, true
+	case "eta":
+		if st.State != client.StateDownloading || st.DownloadRate == 0 {
+			return -1, true
+		}
+		return st.BytesLeft / int64(st.DownloadRate), true
+	case "rateUpload":
+		return st.UploadRate, true
+	case "downloadedEver":
+		return st.BytesDownloaded, true
+	case "uploadedEver":
+		return st.BytesUploaded, true
+	case "downloadLimit", "uploadLimit":
+		download, upload := t.RateLimits()
+		if field == "downloadLimit" {
+			return download / trSpeedBytes, true
+		}
+		return upload / trSpeedBytes, true
+	case "downloadLimited", "uploadLimited":
+		download, upload := t.RateLimits()
+		if field == "downloadLimited" {
+			return download > 0, true
+		}
+		return upload > 0, true
+	case "peersConnected", "peersSendingToUs":
+		return st.Peers, true
+	case "downloadDir":
+		return filepath.Dir(t.Path()), true
+	case "pieceCount":
+		return st.PiecesTotal, true
+	case "pieceSize":
+		if info == nil {
+			return 0, true
+		}
+		return info.PieceLength, true
+	case "files":
+		files := []map[string]interface{}{}
+		for _, f := range t.Files() {
+			name := f.Path
+			if info.MultiFile {
+				name = t.Name() + "/" + f.Path
+			}
+			files = append(files, map[string]interface{}{
+				"name":           name,
+				"length":         f.Length,
+				"bytesCompleted": f.BytesCompleted,
+			})
+		}
+		return files, true
+	case "fileStats":
+		stats := []map[string]interface{}{}
+		for _, f := range t.Files() {
+			stats = append(stats, map[string]interface{}{
+				"bytesCompleted": f.BytesCompleted,
+				"wanted":         f.Priority != client.PrioritySkip,
+				"priority":       trPriority(f.Priority),
+			})
+		}
+		return stats, true
+	case "priorities":
+		prios := []int{}
+		for _, f := range t.Files() {
+			prios = append(prios, trPriority(f.Priority))
+		}
+		return prios, true
+	case "wanted":
+		wanted := []int{}
+		for _, f := range t.Files() {
+			if f.Priority == client.PrioritySkip {
+				wanted = append(wanted, 0)
+			} else {
+				wanted = append(wanted, 1)
+			}
+		}
+		return wanted, true
+	case "peers":
+		peers := []map[string]interface{}{}
+		for _, p := range t.Peers() {
+			host, port, _ := net.SplitHostPort(p.Addr)
+			portNum, _ := strconv.Atoi(port)
+			peers = append(peers, map[string]interface{}{
+				"address":           host,
+				"port":              portNum,
+				"clientName":        clientName(p.PeerID),
+				"isIncoming":        p.Incoming,
+				"isDownloadingFrom": true,
+				"isUploadingTo":     false,
+				"rateToClient":      p.DownloadRate,
+				"rateToPeer":        p.UploadRate,
+				"flagStr":           "D",
+			})
+		}
+		return peers, true
+	}
+	return nil, false
+}
+
+// trPriority converts a priority to Transmission's -1, 0 or 1. Skipped
+// files report normal priority; they are marked as not wanted instead.
+func trPriority(p client.Priority) int {
+	if p == client.PrioritySkip {
+		return 0
+	}
+	return int(p)
+}
+
+// clientName guesses the client of a peer from an Azureus style peer ID
+// such as "-TR4000-...".
+func clientName(peerID string) string {
+	if len(peerID) >= 8 && peerID[0] == '-' && peerID[7] == '-' {
+		return peerID[1:7]
+	}
+	return ""
+}
+
+func (s *TransmissionServer) torrentSet(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		IDs             json.RawMessage `json:"ids"`
+		FilesWanted     []int           `json:"files-wanted"`
+		FilesUnwanted   []int           `json:"files-unwanted"`
+		PriorityHigh    []int           `json:"priority-high"`
+		PriorityLow     []int           `json:"priority-low"`
+		PriorityNormal  []int           `json:"priority-normal"`
+		DownloadLimit   *int            `json:"downloadLimit"`
+		DownloadLimited *bool           `json:"downloadLimited"`
+		UploadLimit     *int            `json:"uploadLimit"`
+		UploadLimited   *bool           `json:"uploadLimited"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	torrents, err := s.torrents(a.IDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range torrents {
+		if err := setFiles(t, a.FilesWanted, a.FilesUnwanted, a.PriorityHigh, a.PriorityLow, a.PriorityNormal); err != nil {
+			return nil, err
+		}
+		download, upload := t.RateLimits()
+		t.SetRateLimits(trLimit(download, a.DownloadLimit, a.DownloadLimited), trLimit(upload, a.UploadLimit, a.UploadLimited))
+	}
+	return nil, nil
+}
+
+// trLimit applies the limit and limited arguments of torrent-set to a rate
+// limit in bytes per second.
+func trLimit(rate int, limit *int, limited *bool) int {
+	if limited != nil && !*limited {
+		return 0
+	}
+	if limit != nil {
+		return max(*limit, 0) * trSpeedBytes
+	}
+	return rate
+}
+
+func (s *TransmissionServer) torrentStart(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		IDs json.RawMessage `json:"ids"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	torrents, err := s.torrents(a.IDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range torrents {
+		if err := t.Start(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *TransmissionServer) torrentStop(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		IDs json.RawMessage `json:"ids"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	torrents, err := s.torrents(a.IDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range torrents {
+		t.Pause()
+	}
+	return nil, nil
+}
+
+func (s *TransmissionServer) torrentRemove(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		IDs             json.RawMessage `json:"ids"`
+		DeleteLocalData bool            `json:"delete-local-data"`
+	}
+	if err := decodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	torrents, err := s.torrents(a.IDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range torrents {
+		if err := s.client.Remove(t.InfoHash(), a.DeleteLocalData); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+// addTestTorrent adds a torrent named name to c, saving into a temporary
+// directory, with an unreachable peer so that it needs no tracker.
+func addTestTorrent(t *testing.T, c *client.Client, name string) *client.Torrent {
+	t.Helper()
+	data, _ := base64.StdEncoding.DecodeString(torrentFile(name))
+	info, err := metainfo.FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := c.AddTorrentInfo(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.SetOutputPath(filepath.Join(t.TempDir(), name))
+	tor.AddPeers("127.0.0.1:1")
+	return tor
+}
+
+// waitForState waits until tor is in state.
+func waitForState(t *testing.T, tor *client.Torrent, state client.State) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for tor.Stats().State != state {
+		if ctx.Err() != nil {
+			t.Fatalf("%s: state = %v, want %v", tor.Name(), tor.Stats().State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTorrentGetStatus(t *testing.T) {
+	// a listening client keeps downloading while it has no peers
+	c, err := client.NewClient(client.Config{ListenAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	downloading := addTestTorrent(t, c, "downloading")
+	if err := downloading.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, downloading, client.StateDownloading)
+
+	// with its only file skipped, a torrent completes at once
+	seeding := addTestTorrent(t, c, "seeding")
+	if err := seeding.SetFilePriorities([]int{0}, client.PrioritySkip); err != nil {
+		t.Fatal(err)
+	}
+	if err := seeding.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, seeding, client.StateCompleted)
+
+	stopped := addTestTorrent(t, c, "stopped")
+	paused := addTestTorrent(t, c, "paused")
+	if err := paused.Start(); err != nil {
+		t.Fatal(err)
+	}
+	paused.Pause()
+
+	body := call(t, NewTransmissionServer(c), SessionIDHeader,
+		`{"method": "torrent-get", "arguments": {"fields": ["hashString", "status", "isFinished", "leftUntilDone", "sizeWhenDone"]}}`)
+	var resp struct {
+		Result    string `json:"result"`
+		Arguments struct {
+			Torrents []struct {
+				HashString    string `json:"hashString"`
+				Status        int    `json:"status"`
+				IsFinished    bool   `json:"isFinished"`
+				LeftUntilDone int64  `json:"leftUntilDone"`
+				SizeWhenDone  int64  `json:"sizeWhenDone"`
+			} `json:"torrents"`
+		} `json:"arguments"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != "success" {
+		t.Fatalf("torrent-get result = %q", resp.Result)
+	}
+
+	type status struct {
+		status       int
+		finished     bool
+		left, wanted int64
+	}
+	want := map[string]status{
+		hex.EncodeToString(downloading.InfoHash()): {trStatusDownload, false, 3, 3},
+		hex.EncodeToString(seeding.InfoHash()):     {trStatusSeed, true, 0, 0},
+		hex.EncodeToString(stopped.InfoHash()):     {trStatusStopped, false, 3, 3},
+		hex.EncodeToString(paused.InfoHash()):      {trStatusStopped, false, 3, 3},
+	}
+	if len(resp.Arguments.Torrents) != len(want) {
+		t.Fatalf("torrent-get returned %d torrents, want %d", len(resp.Arguments.Torrents), len(want))
+	}
+	for _, got := range resp.Arguments.Torrents {
+		w, ok := want[got.HashString]
+		if !ok {
+			t.Errorf("unexpected torrent %s", got.HashString)
+			continue
+		}
+		if g := (status{got.Status, got.IsFinished, got.LeftUntilDone, got.SizeWhenDone}); g != w {
+			t.Errorf("torrent %s = %+v, want %+v", got.HashString, g, w)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Package storage writes downloaded torrent data to disk.
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// File is a single output file split into pieces. Pieces are written to a
+// temporary file next to the destination as they arrive, and Commit renames
+// it into place, so an interrupted download never leaves a truncated file
+// behind.
+type File struct {
+	f           *os.File
+	path        string
+	length      int64
+	pieceLength int
+}
+
+// Create prepares the output file for a torrent of length bytes split into
+// pieces of pieceLength bytes.
+func Create(path string, length int64, pieceLength int) (*File, error) {
+	f, err := createTemp(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(length); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &File{
+		f:           f,
+		path:        path,
+		length:      length,
+		pieceLength: pieceLength,
+	}, nil
+}
+
+// createTemp creates the temporary file for path in the same directory.
+func createTemp(path string) (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp uses 0600, which would stay with the file once renamed
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// WritePiece writes the data of piece index at its offset in the file.
+func (f *File) WritePiece(index int, data []byte) error {
+	offset := int64(index) * int64(f.pieceLength)
+	if index < 0 || offset+int64(len(data)) > f.length {
+		return fmt.Errorf("piece %d does not fit in file of %d bytes", index, f.length)
+	}
+	_, err := f.f.WriteAt(data, offset)
+	return err
+}
+
+// Commit flushes the file and moves it to its destination.
+func (f *File) Commit() error {
+	if err := f.f.Close(); err != nil {
+		os.Remove(f.f.Name())
+		return err
+	}
+	return os.Rename(f.f.Name(), f.path)
+}
+
+// Abort discards everything written so far.
+func (f *File) Abort() error {
+	f.f.Close()
+	return os.Remove(f.f.Name())
+}
+
+// WriteFile writes data to the named file through a temporary file, so an
+// interrupted write never leaves a truncated file behind. If name exists
+// and is not a regular file, such as /dev/stdout, data is written to it
+// directly instead of replacing it.
+func WriteFile(name string, data []byte) error {
+	if info, err := os.Stat(name); err == nil && !info.Mode().IsRegular() {
+		return os.WriteFile(name, data, 0644)
+	}
+	f, err := Create(name, int64(len(data)), len(data))
+	if err != nil {
+		return err
+	}
+	if _, err := f.f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// Entry describes one file of a Set.
+type Entry struct {
+	// Path is the destination of the file.
+	Path   string
+	Length int64
+}
+
+// Set is the output of a torrent whose data spans one or more files laid
+// out back to back. Each file is created as a temporary file next to its
+// destination when the first piece touching it is written, and Commit
+// moves the files into place. Data for skipped files is dropped.
+//
+// A Set stays usable after Commit: writing to a committed file updates it
+// in place, which lets a download resume after a skipped file is
+// unskipped.
+type Set struct {
+	pieceLength int
+	length      int64
+
+	mu    sync.Mutex
+	files []*setFile
+	// dirs lists the directories created for the files, parents first.
+	dirs []string
+}
+
+type setFile struct {
+	Entry
+	offset    int64
+	skip      bool
+	f         *os.File
+	committed bool
+}
+
+// NewSet returns a Set for the given files split into pieces of
+// pieceLength bytes. No file is created until data is written to it.
+func NewSet(pieceLength int, entries []Entry) *Set {
+	s := &Set{pieceLength: pieceLength}
+	for _, e := range entries {
+		s.files = append(s.files, &setFile{Entry: e, offset: s.length})
+		s.length += e.Length
+	}
+	return s
+}
+
+// Skip sets whether file i is skipped.
+func (s *Set) Skip(i int, skip bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[i].skip = skip
+}
+
+// WritePiece writes the data of piece index to the files it spans.
+func (s *Set) WritePiece(index int, data []byte) error {
+	start := int64(index) * int64(s.pieceLength)
+	end := start + int64(len(data))
+	if index < 0 || end > s.length {
+		return fmt.Errorf("piece %d does not fit in %d bytes", index, s.length)
+	}
+	for _, sf := range s.files {
+		if sf.offset >= end || sf.offset+sf.Length <= start {
+			continue
+		}
+		f, err := s.open(sf)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		from := max(start, sf.offset)
+		to := min(end, sf.offset+sf.Length)
+		if _, err := f.WriteAt(data[from-start:to-start], from-sf.offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open returns the file to write the data of sf to, creating it if needed.
+// It returns nil if sf is skipped.
+func (s *Set) open(sf *setFile) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sf.skip {
+		return nil, nil
+	}
+	if sf.f != nil {
+		return sf.f, nil
+	}
+	if sf.committed {
+		f, err := os.OpenFile(sf.Path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		sf.f = f
+		return f, nil
+	}
+
+	if err := s.mkdirAll(filepath.Dir(sf.Path)); err != nil {
+		return nil, err
+	}
+	f, err := createTemp(sf.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(sf.Length); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	sf.f = f
+	return f, nil
+}
+
+// mkdirAll creates dir and its missing parents and remembers which
+// directories it created. It must be called with s.mu held.
+func (s *Set) mkdirAll(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		s.dirs = append(s.dirs, missing[i])
+	}
+	return nil
+}
+
+// Commit flushes every file written so far and moves it to its
+// destination. Empty files that are not skipped are created as well.
+func (s *Set) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sf := range s.files {
+		if sf.f == nil && sf.Length == 0 && !sf.skip && !sf.committed {
+			if err := s.mkdirAll(filepath.Dir(sf.Path)); err != nil {
+				return err
+			}
+			if err := os.WriteFile(sf.Path, nil, 0644); err != nil {
+				return err
+			}
+			sf.committed = true
+			continue
+		}
+		if sf.f == nil {
+			continue
+		}
+		name := sf.f.Name()
+		err := sf.f.Close()
+		sf.f = nil
+		if sf.committed {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			os.Remove(name)
+			return err
+		}
+		if err := os.Rename(name, sf.Path); err != nil {
+			return err
+		}
+		sf.committed = true
+	}
+	return nil
+}
+
+// Abort discards the files that were not committed yet. Committed files
+// are kept.
+func (s *Set) Abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, sf := range s.files {
+		if sf.f == nil {
+			continue
+		}
+		sf.f.Close()
+		if !sf.committed {
+			if rerr := os.Remove(sf.f.Name()); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+		sf.f = nil
+	}
+	s.removeDirs()
+	return err
+}
+
+// Delete removes every file of the set, committed or not, and the
+// directories created for them.
+func (s *Set) Delete() error {
+	err := s.Abort()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sf := range s.files {
+		if !sf.committed {
+			continue
+		}
+		if rerr := os.Remove(sf.Path); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+			err = rerr
+		}
+		sf.committed = false
+	}
+	s.removeDirs()
+	return err
+}
+
+// removeDirs removes the directories created for the files that are empty.
+// It must be called with s.mu held.
+func (s *Set) removeDirs() {
+	var kept []string
+	for i := len(s.dirs) - 1; i >= 0; i-- {
+		if os.Remove(s.dirs[i]) != nil {
+			kept = append(kept, s.dirs[i])
+		}
+	}
+	slices.Reverse(kept)
+	s.dirs = kept
+}
@@ -0,0 +1,65 @@
+package tracker
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/bencode"
+)
+
+// announceReply is the encoding of a tracker's reply to an announce.
+type announceReply struct {
+	FailureReason string `bencode:"failure reason"`
+	Interval      int    `bencode:"interval"`
+	// Peers is either a compact byte string or a list of peerDict.
+	Peers bencode.RawMessage `bencode:"peers"`
+}
+
+type peerDict struct {
+	IP     string `bencode:"ip"`
+	Port   int    `bencode:"port"`
+	PeerID string `bencode:"peer id"`
+}
+
+// maxResponseString bounds the byte strings of a tracker response, which
+// holds at most a few thousand peers.
+const maxResponseString = 1 << 20
+
+func parseResponse(r io.Reader) (*AnnounceResponse, error) {
+	d := bencode.NewDecoder(r)
+	d.SetMaxDepth(8)
+	d.SetMaxStringLength(maxResponseString)
+	var reply announceReply
+	if err := d.DecodeInto(&reply); err != nil {
+		return nil, fmt.Errorf("invalid tracker response: %w", err)
+	}
+	if reply.FailureReason != "" {
+		return nil, fmt.Errorf("tracker failure: %s", reply.FailureReason)
+	}
+
+	resp := &AnnounceResponse{Interval: reply.Interval}
+	var compact []byte
+	var peers []bencode.RawMessage
+	if bencode.Unmarshal(reply.Peers, &compact) == nil {
+		resp.Peers = ParseCompactPeers(compact)
+	} else if bencode.Unmarshal(reply.Peers, &peers) == nil {
+		for _, p := range peers {
+			// skip malformed entries rather than the whole list
+			var peer peerDict
+			if err := bencode.Unmarshal(p, &peer); err != nil || peer.IP == "" || peer.Port == 0 {
+				continue
+			}
+			addr := net.JoinHostPort(peer.IP, strconv.Itoa(peer.Port))
+			resp.Peers = append(resp.Peers, addr)
+			if peer.PeerID != "" {
+				if resp.PeerIDs == nil {
+					resp.PeerIDs = make(map[string]string)
+				}
+				resp.PeerIDs[addr] = peer.PeerID
+			}
+		}
+	}
+	return resp, nil
+}
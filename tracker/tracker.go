@@ -0,0 +1,93 @@
+// Package tracker implements the client side of the HTTP tracker protocol.
+package tracker
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Announce events.
+const (
+	EventNone      = ""
+	EventStarted   = "started"
+	EventStopped   = "stopped"
+	EventCompleted = "completed"
+)
+
+// unknownLeft is reported as the number of bytes left when the torrent size
+// is not known yet, as for magnet links before the metadata arrived.
+// Trackers reject announces without a positive "left".
+const unknownLeft = 999
+
+// AnnounceRequest describes a single announce to a tracker.
+type AnnounceRequest struct {
+	TrackerURL string
+	InfoHash   []byte
+	PeerID     string
+	Port       int
+	Uploaded   int64
+	Downloaded int64
+	// Left is the number of bytes still to download, or -1 if unknown.
+	Left  int64
+	Event string
+}
+
+// URL returns the announce URL for r.
+func (r AnnounceRequest) URL() string {
+	left := r.Left
+	if left == -1 {
+		left = unknownLeft
+	}
+
+	val := url.Values{}
+	val.Add("peer_id", r.PeerID)
+	val.Add("port", fmt.Sprint(r.Port))
+	val.Add("uploaded", fmt.Sprint(r.Uploaded))
+	val.Add("downloaded", fmt.Sprint(r.Downloaded))
+	val.Add("left", fmt.Sprint(left))
+	val.Add("compact", "1")
+	val.Add("info_hash", string(r.InfoHash))
+	if r.Event != EventNone {
+		val.Add("event", r.Event)
+	}
+
+	return r.TrackerURL + "?" + val.Encode()
+}
+
+// AnnounceResponse is a tracker's reply to an announce.
+type AnnounceResponse struct {
+	// Interval is the number of seconds the tracker wants us to wait
+	// between regular announces, or 0 if it did not say.
+	Interval int
+	// Peers holds the peer addresses in host:port form.
+	Peers []string
+	// PeerIDs maps peer addresses to their peer IDs. Only trackers that
+	// send a non-compact peer list include them.
+	PeerIDs map[string]string
+}
+
+// Announce sends r to its tracker and returns the tracker's reply.
+func Announce(ctx context.Context, r AnnounceRequest) (*AnnounceResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return parseResponse(resp.Body)
+}
+
+// ParseCompactPeers parses a compact IPv4 peer list, six bytes per peer.
+func ParseCompactPeers(ips []byte) []string {
+	ipAddrs := make([]string, 0, len(ips)/6)
+	for i := 0; i+6 <= len(ips); i += 6 {
+		ipAddrs = append(ipAddrs, fmt.Sprintf("%d.%d.%d.%d:%d", ips[i], ips[i+1], ips[i+2], ips[i+3], binary.BigEndian.Uint16(ips[i+4:i+6])))
+	}
+	return ipAddrs
+}
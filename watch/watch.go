@@ -0,0 +1,199 @@
+// Package watch adds torrents dropped into a directory to a client.
+//
+// The directory is polled for .torrent files and for .magnet files holding
+// a magnet link. Each file is added and started, then renamed by appending
+// ".added", or ".invalid" if it could not be parsed or started. A file in a
+// subdirectory of the watched directory is downloaded to the same
+// subdirectory of the output directory.
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+	"github.com/codecrafters-io/bittorrent-starter-go/magnet"
+	"github.com/codecrafters-io/bittorrent-starter-go/metainfo"
+)
+
+// DefaultInterval is the polling interval used if Watcher.Interval is zero.
+const DefaultInterval = 2 * time.Second
+
+// Suffixes appended to processed files.
+const (
+	AddedSuffix   = ".added"
+	InvalidSuffix = ".invalid"
+)
+
+// Watcher watches a directory for torrents.
+type Watcher struct {
+	// Dir is the watched directory.
+	Dir string
+	// OutputDir is the directory torrents are downloaded to. The client's
+	// download directory is used if it is empty.
+	OutputDir string
+	Client    *client.Client
+	Interval  time.Duration
+	// Added, if set, is called for every processed file with the torrent
+	// that was added, or the error that made the file invalid.
+	Added func(path string, t *client.Torrent, err error)
+}
+
+// fileState is what a scan saw of a file.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Run polls the directory until ctx is done. A file is only picked up once
+// its size and modification time did not change between two scans, so
+// files still being written are left alone.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	seen := make(map[string]fileState)
+	for {
+		if err := w.scan(seen); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// scan processes the files that are stable since the previous scan, and
+// records the others in seen.
+func (w *Watcher) scan(seen map[string]fileState) error {
+	current := make(map[string]fileState)
+	err := filepath.WalkDir(w.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == w.Dir {
+				return err
+			}
+			// the entry may have been removed since it was listed
+			return nil
+		}
+		if d.IsDir() || !isTorrentFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if prev, ok := seen[path]; !ok || prev != state {
+			current[path] = state
+			return nil
+		}
+		w.process(path)
+		return nil
+	})
+	for path := range seen {
+		delete(seen, path)
+	}
+	for path, state := range current {
+		seen[path] = state
+	}
+	return err
+}
+
+func isTorrentFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".torrent" || ext == ".magnet"
+}
+
+// process adds the torrent in path and renames the file.
+func (w *Watcher) process(path string) {
+	t, err := w.add(path)
+	suffix := AddedSuffix
+	if err != nil {
+		suffix = InvalidSuffix
+	}
+	if rerr := os.Rename(path, path+suffix); rerr != nil && err == nil {
+		err = rerr
+	}
+	if w.Added != nil {
+		w.Added(path, t, err)
+	}
+}
+
+func (w *Watcher) add(path string) (*client.Torrent, error) {
+	var infoHash []byte
+	var add func() (*client.Torrent, error)
+	if strings.EqualFold(filepath.Ext(path), ".magnet") {
+		link, err := readMagnet(path)
+		if err != nil {
+			return nil, err
+		}
+		mag, err := magnet.Parse(link)
+		if err != nil {
+			return nil, err
+		}
+		infoHash = mag.InfoHash
+		add = func() (*client.Torrent, error) { return w.Client.AddMagnet(link) }
+	} else {
+		info, err := metainfo.FromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		infoHash = info.InfoHash
+		add = func() (*client.Torrent, error) { return w.Client.AddTorrentInfo(info) }
+	}
+
+	if t := w.Client.Torrent(infoHash); t != nil {
+		// already in the session
+		return t, nil
+	}
+	t, err := add()
+	if err != nil {
+		return nil, err
+	}
+	if dir := w.outputDir(path); dir != "" {
+		t.SetDownloadDir(dir)
+	}
+	if err := t.Start(); err != nil {
+		// the file is marked invalid, so the torrent must not stay behind
+		w.Client.Remove(infoHash, false)
+		return nil, err
+	}
+	return t, nil
+}
+
+// readMagnet returns the magnet link on the first non-empty line of the
+// named file.
+func readMagnet(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+	}
+	return "", errors.New("magnet file is empty")
+}
+
+// outputDir returns the download directory for the torrent in path, which
+// mirrors the subdirectory of the watched directory path is in.
+func (w *Watcher) outputDir(path string) string {
+	base := w.OutputDir
+	if base == "" {
+		base = w.Client.Config().DownloadDir
+	}
+	rel, err := filepath.Rel(w.Dir, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return base
+	}
+	return filepath.Join(base, rel)
+}
@@ -0,0 +1,168 @@
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/bittorrent-starter-go/client"
+)
+
+// torrentFile returns a single-file .torrent named name.
+func torrentFile(name string) string {
+	return fmt.Sprintf("d4:infod6:lengthi3e4:name%d:%s12:piece lengthi16384e6:pieces20:%see", len(name), name, strings.Repeat("a", 20))
+}
+
+type added struct {
+	path string
+	t    *client.Torrent
+	err  error
+}
+
+func newTestWatcher(t *testing.T) (*Watcher, *[]added) {
+	t.Helper()
+	c, err := client.NewClient(client.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	var results []added
+	w := &Watcher{
+		Dir:       t.TempDir(),
+		OutputDir: t.TempDir(),
+		Client:    c,
+		Added: func(path string, tor *client.Torrent, err error) {
+			results = append(results, added{path, tor, err})
+		},
+	}
+	return w, &results
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestScanWaitsForStableFiles(t *testing.T) {
+	w, results := newTestWatcher(t)
+	path := filepath.Join(w.Dir, "a.torrent")
+	writeFile(t, path, torrentFile("a")[:10])
+	seen := make(map[string]fileState)
+
+	if err := w.scan(seen); err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != 0 {
+		t.Fatalf("new file processed on its first scan: %+v", *results)
+	}
+
+	// still being written
+	writeFile(t, path, torrentFile("a"))
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	if err := w.scan(seen); err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != 0 {
+		t.Fatalf("changed file processed: %+v", *results)
+	}
+
+	if err := w.scan(seen); err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != 1 || (*results)[0].err != nil {
+		t.Fatalf("stable file not added: %+v", *results)
+	}
+	if exists(path) || !exists(path+AddedSuffix) {
+		t.Errorf("%s not renamed to %s", path, path+AddedSuffix)
+	}
+
+	// renamed files are not picked up again
+	for range 2 {
+		if err := w.scan(seen); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*results) != 1 {
+		t.Errorf("files processed = %d, want 1", len(*results))
+	}
+}
+
+func TestProcess(t *testing.T) {
+	w, results := newTestWatcher(t)
+	files := []struct {
+		name   string
+		data   string
+		suffix string
+		// dir is the download directory of the torrent, relative to
+		// OutputDir
+		dir string
+	}{
+		{"a.torrent", torrentFile("a"), AddedSuffix, "."},
+		{"sub/dir/b.TORRENT", torrentFile("b"), AddedSuffix, "sub/dir"},
+		{"c.magnet", "\nmagnet:?xt=urn:btih:" + strings.Repeat("c", 40) + "&dn=c\n", AddedSuffix, "."},
+		{"bad.torrent", "d4:infoe", InvalidSuffix, ""},
+		{"bad.magnet", "magnet:?dn=x", InvalidSuffix, ""},
+		{"empty.magnet", "\n", InvalidSuffix, ""},
+		{"ignored.txt", "x", "", ""},
+	}
+	for _, f := range files {
+		writeFile(t, filepath.Join(w.Dir, f.name), f.data)
+	}
+	seen := make(map[string]fileState)
+	for range 2 {
+		if err := w.scan(seen); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byPath := make(map[string]added)
+	for _, r := range *results {
+		byPath[r.path] = r
+	}
+	for _, f := range files {
+		path := filepath.Join(w.Dir, f.name)
+		r, ok := byPath[path]
+		if f.suffix == "" {
+			if ok || !exists(path) {
+				t.Errorf("%s: processed, want it left alone", f.name)
+			}
+			continue
+		}
+		if !exists(path + f.suffix) {
+			t.Errorf("%s: not renamed to %s", f.name, f.name+f.suffix)
+		}
+		if !ok {
+			t.Errorf("%s: Added not called", f.name)
+			continue
+		}
+		if (r.err != nil) != (f.suffix == InvalidSuffix) {
+			t.Errorf("%s: error = %v", f.name, r.err)
+		}
+		if f.dir == "" {
+			continue
+		}
+		if r.t == nil {
+			t.Errorf("%s: no torrent", f.name)
+			continue
+		}
+		if got, want := filepath.Dir(r.t.Path()), filepath.Join(w.OutputDir, f.dir); got != want {
+			t.Errorf("%s: saved to %s, want %s", f.name, got, want)
+		}
+	}
+	if n := len(w.Client.Torrents()); n != 3 {
+		t.Errorf("client has %d torrents, want 3", n)
+	}
+}